@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command a sub command of the go-api binary, run instead of the server.
+type command func(args []string) error
+
+var commands = map[string]command{
+	"encryption": encryptionCommand,
+	"migrate":    migrateCommand,
+	"seed":       seedCommand,
+	"tenant":     tenantCommand,
+}
+
+// runCommand run the sub command given as first argument and exit.
+func runCommand(name string, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "unknown command %q, available commands: %s\n", name, strings.Join(names, ", "))
+		os.Exit(2)
+	}
+
+	if err := cmd(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alpakih/go-api/pkg/database"
+	"gorm.io/gorm"
+)
+
+const encryptionUsage = `usage: go-api encryption <command>
+
+commands:
+  keygen  print a new random key, to add to encryption.keys or use as encryption.blindIndexKey
+  rotate  re-encrypt the values encrypted with an older key with encryption.currentKey`
+
+func encryptionCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(encryptionUsage)
+	}
+
+	switch args[0] {
+	case "keygen":
+		key, err := database.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	case "rotate":
+		registerModels()
+		db, err := database.GetConnection()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+		return rotateEncryption(context.Background(), db)
+	default:
+		return errors.New(encryptionUsage)
+	}
+}
+
+// rotateEncryption re-encrypt the values of the registered models and, with
+// SchemaPerTenant, those of the tenant models in the schema of every tenant.
+func rotateEncryption(ctx context.Context, db *gorm.DB) error {
+	if !database.SchemaPerTenant() {
+		rotated, err := database.RotateEncryption(ctx, db, database.GetRegisteredModels()...)
+		if err != nil {
+			return err
+		}
+		fmt.Println("re-encrypted", rotated, "values")
+		return nil
+	}
+
+	var shared, perTenant []interface{}
+	for _, model := range database.GetRegisteredModels() {
+		if database.IsSharedModel(model) {
+			shared = append(shared, model)
+		} else {
+			perTenant = append(perTenant, model)
+		}
+	}
+	rotated, err := database.RotateEncryption(ctx, db, shared...)
+	if err != nil {
+		return err
+	}
+	fmt.Println("re-encrypted", rotated, "values of the shared tables")
+	return database.ForEachTenant(ctx, func(ctx context.Context, tenant database.Tenant) error {
+		rotated, err := database.RotateEncryption(ctx, database.FromContext(ctx, db), perTenant...)
+		if err != nil {
+			return err
+		}
+		fmt.Println("re-encrypted", rotated, "values of tenant", tenant.ID)
+		return nil
+	})
+}
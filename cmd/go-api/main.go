@@ -2,19 +2,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/alpakih/go-api/internal/domain"
 	_userHttpDelivery "github.com/alpakih/go-api/internal/users/delivery/http"
-	_userRepo "github.com/alpakih/go-api/internal/users/repository/mysql"
+	_userRepo "github.com/alpakih/go-api/internal/users/repository/relational"
 	_userService "github.com/alpakih/go-api/internal/users/service"
 	"github.com/alpakih/go-api/pkg/database"
-	_ "github.com/alpakih/go-api/pkg/database/dialect/mysql"
+	_ "github.com/alpakih/go-api/pkg/database/dialect/all"
 	"github.com/alpakih/go-api/pkg/env"
 	"github.com/alpakih/go-api/pkg/intercept"
 	"github.com/alpakih/go-api/pkg/logging"
+	"github.com/alpakih/go-api/pkg/outbox"
 	"github.com/alpakih/go-api/pkg/validation"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/log"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 	"io"
 	"net/http"
 	"os"
@@ -22,28 +26,115 @@ import (
 	"time"
 )
 
+func registerModels() {
+	database.RegisterModel(domain.User{})
+	database.RegisterSharedModel(outbox.Event{})
+}
+
+// migrateUsernames backfill the canonical usernames on every connection and, with
+// SchemaPerTenant, in the schema of every tenant, logging the usernames colliding
+// once normalized. It fails as long as collisions remain.
+func migrateUsernames(ctx context.Context) error {
+	count := 0
+	migrate := func(where string, db *gorm.DB) error {
+		collisions, err := _userRepo.MigrateUsernameCanonical(db)
+		if err != nil {
+			return err
+		}
+		for _, collision := range collisions {
+			for _, user := range collision.Users {
+				log.Errorf("%s: username collision %q: user %s (%q)", where, collision.Canonical, user.ID, user.UserName)
+			}
+		}
+		count += len(collisions)
+		return nil
+	}
+
+	for _, name := range database.ConnectionNames() {
+		db, err := database.GetNamedConnection(name)
+		if err != nil {
+			return err
+		}
+		if err := migrate("database connection "+name, db); err != nil {
+			return fmt.Errorf("database connection %s: %w", name, err)
+		}
+	}
+
+	if database.SchemaPerTenant() {
+		db, err := database.GetConnection()
+		if err != nil {
+			return err
+		}
+		// no tenant before the first migration
+		if db.Migrator().HasTable(&database.Tenant{}) {
+			if err := database.ForEachTenant(ctx, func(ctx context.Context, tenant database.Tenant) error {
+				return migrate("tenant "+tenant.ID, database.FromContext(ctx, db))
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if count > 0 {
+		return fmt.Errorf("%d canonical usernames are shared by several users, resolve them before migrating", count)
+	}
+	return nil
+}
+
 func main() {
 
 	env.LoadEnvironment()
 
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+	}
+
 	// Echo instance
 	e := echo.New()
 
-	db := database.GetConnection()
+	db, err := database.GetConnection()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if viper.GetBool("database.autoMigrate") {
-		database.RegisterModel(domain.User{})
-		database.Migrate()
+		if err := migrateUsernames(context.Background()); err != nil {
+			log.Fatalf("migrate usernames: %s", err)
+		}
+
+		registerModels()
+		if err := database.Migrate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// background workers, stopped on shutdown
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go database.CollectStats(backgroundCtx, time.Duration(viper.GetInt("database.statsInterval"))*time.Second, database.LogStats)
+
+	if viper.GetBool("outbox.relay.enabled") {
+		sink, err := outbox.DefaultSink()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go outbox.NewRelay(db, sink).Run(backgroundCtx)
 	}
 
 	// Set Middleware
 	e.Use(middleware.Recover())
+	e.Use(intercept.RequestTimeout())
+	e.Use(intercept.ReadYourWrites())
+	e.Use(intercept.QueryStats())
+	e.Use(intercept.Language())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization,
+			viper.GetString("database.tenancy.header")},
 		AllowMethods: []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
 	}))
 
+	validation.SetPasswordPolicy(validation.PasswordPolicyFromConfig())
 	e.Validator = validation.NewValidator()
 
 	// setup log folder,file and log global
@@ -59,11 +150,21 @@ func main() {
 		return c.JSON(http.StatusOK, "GO API")
 	})
 
+	// health check, failing while the database is unreachable
+	e.GET("/health", func(c echo.Context) error {
+		if err := database.Ping(c.Request().Context()); err != nil {
+			c.Logger().Error(err)
+			return c.JSON(http.StatusServiceUnavailable, "UNAVAILABLE")
+		}
+		return c.JSON(http.StatusOK, "OK")
+	})
+
 	apiGroup := e.Group("/api")
 	{
-		v1 := apiGroup.Group("/v1", middleware.JWTWithConfig(intercept.JwtMiddleware().JwtConfig()))
+		// the tenant is looked up once the user is authenticated, from the token
+		v1 := apiGroup.Group("/v1", middleware.JWTWithConfig(intercept.JwtMiddleware().JwtConfig()), intercept.Tenant())
 		{
-			userRepository := _userRepo.NewMysqlUserRepository(db)
+			userRepository := _userRepo.NewUserRepository(db)
 			userService := _userService.NewUserService(userRepository)
 			userHandler := _userHttpDelivery.NewUserHandler(userService)
 
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/alpakih/go-api/pkg/database"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: go-api migrate [--connection=name] <command>
+
+commands:
+  up [n]         apply all pending migrations, or the next n
+  down [n]       revert the last applied migration, or the last n
+  status         list migrations and whether they are applied
+  create <name>  create empty up and down SQL migration files`
+
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	connection := flags.String("connection", database.DefaultConnection, "name of the connection to migrate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			return errors.New("usage: go-api migrate [--connection=name] create <name>")
+		}
+		paths, err := database.CreateMigration(database.MigrationsDir(*connection), args[1], time.Now())
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return nil
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of migrations %q", args[1])
+		}
+		steps = n
+	}
+
+	migrator, err := database.NamedMigrator(*connection)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if args[0] != "up" && args[0] != "down" && args[0] != "status" {
+		return errors.New(migrateUsage)
+	}
+
+	ctx := context.Background()
+	if *connection == database.DefaultConnection && database.SchemaPerTenant() {
+		// the tables live in the tenant schemas, the public schema only has the shared ones
+		return database.ForEachTenant(ctx, func(ctx context.Context, tenant database.Tenant) error {
+			fmt.Printf("tenant %s:\n", tenant.ID)
+			return runMigrations(ctx, migrator, args[0], steps)
+		})
+	}
+	return runMigrations(ctx, migrator, args[0], steps)
+}
+
+// runMigrations run the given migrate command in the database, or tenant, of ctx.
+func runMigrations(ctx context.Context, migrator *database.Migrator, command string, steps int) error {
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx, steps)
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("nothing to migrate")
+		}
+		return err
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("nothing to revert")
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if errors.Is(err, database.ErrNoMigrationsTable) {
+			fmt.Println("no migrations table, no migration was applied")
+		} else if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Modified {
+				state = "modified"
+			}
+			if status.Missing {
+				state = "missing"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return errors.New(migrateUsage)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/alpakih/go-api/internal/users/seeder"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/spf13/viper"
+	"strings"
+)
+
+func registerSeeders() {
+	database.RegisterSeeder(seeder.AdminSeeder())
+	database.RegisterSeeder(seeder.FakeUserSeeder())
+}
+
+func seedCommand(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	only := flags.String("only", "", "comma separated names of the seeders to run, with their dependencies")
+	environment := flags.String("env", viper.GetString("app.environment"), "environment to seed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	registerSeeders()
+
+	options := database.SeedOptions{Environment: *environment}
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			options.Only = append(options.Only, name)
+		}
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	ran, err := database.Seed(db, options)
+	if err != nil {
+		return err
+	}
+	for _, name := range ran {
+		fmt.Println("seeded", name)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/alpakih/go-api/pkg/database"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const tenantUsage = `usage: go-api tenant <command>
+
+commands:
+  create <id>          create the schema of a tenant, its tables, and apply the migrations
+  drop --force <id>    drop the schema of a tenant and all its data
+  list                 list the tenants`
+
+func tenantCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(tenantUsage)
+	}
+
+	registerModels()
+	defer database.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		if len(args) != 2 {
+			return errors.New("usage: go-api tenant create <id>")
+		}
+		tenant, err := database.CreateTenant(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created tenant %s in schema %s\n", tenant.ID, tenant.Schema)
+		return nil
+	case "drop":
+		flags := flag.NewFlagSet("tenant drop", flag.ContinueOnError)
+		force := flags.Bool("force", false, "confirm the data of the tenant is to be deleted")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return errors.New("usage: go-api tenant drop --force <id>")
+		}
+		if !*force {
+			return fmt.Errorf("dropping tenant %s deletes all its data, confirm with --force", flags.Arg(0))
+		}
+		if err := database.DropTenant(ctx, flags.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Println("dropped tenant", flags.Arg(0))
+		return nil
+	case "list":
+		tenants, err := database.Tenants(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSCHEMA\tCREATED AT")
+		for _, tenant := range tenants {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", tenant.ID, tenant.Schema, tenant.CreatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	}
+
+	return errors.New(tenantUsage)
+}
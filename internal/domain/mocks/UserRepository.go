@@ -3,6 +3,10 @@
 package mocks
 
 import (
+	context "context"
+
+	database "github.com/alpakih/go-api/pkg/database"
+
 	domain "github.com/alpakih/go-api/internal/domain"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -12,13 +16,13 @@ type UserRepository struct {
 	mock.Mock
 }
 
-// Delete provides a mock function with given fields: id
-func (_m *UserRepository) Delete(id string) error {
-	ret := _m.Called(id)
+// Delete provides a mock function with given fields: ctx, id
+func (_m *UserRepository) Delete(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -26,22 +30,20 @@ func (_m *UserRepository) Delete(id string) error {
 	return r0
 }
 
-// Fetch provides a mock function with given fields: limit, offset
-func (_m *UserRepository) Fetch(limit int, offset int) ([]domain.User, error) {
-	ret := _m.Called(limit, offset)
+// FindByEmail provides a mock function with given fields: ctx, email
+func (_m *UserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	ret := _m.Called(ctx, email)
 
-	var r0 []domain.User
-	if rf, ok := ret.Get(0).(func(int, int) []domain.User); ok {
-		r0 = rf(limit, offset)
+	var r0 domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, email)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]domain.User)
-		}
+		r0 = ret.Get(0).(domain.User)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int, int) error); ok {
-		r1 = rf(limit, offset)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -49,20 +51,20 @@ func (_m *UserRepository) Fetch(limit int, offset int) ([]domain.User, error) {
 	return r0, r1
 }
 
-// FindByID provides a mock function with given fields: id
-func (_m *UserRepository) FindByID(id string) (domain.User, error) {
-	ret := _m.Called(id)
+// FindByID provides a mock function with given fields: ctx, id
+func (_m *UserRepository) FindByID(ctx context.Context, id string) (domain.User, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 domain.User
-	if rf, ok := ret.Get(0).(func(string) domain.User); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(domain.User)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -70,20 +72,75 @@ func (_m *UserRepository) FindByID(id string) (domain.User, error) {
 	return r0, r1
 }
 
-// FindByUsername provides a mock function with given fields: username
-func (_m *UserRepository) FindByUsername(username string) (domain.User, error) {
-	ret := _m.Called(username)
+// FindByUsername provides a mock function with given fields: ctx, username
+func (_m *UserRepository) FindByUsername(ctx context.Context, username string) (domain.User, error) {
+	ret := _m.Called(ctx, username)
 
 	var r0 domain.User
-	if rf, ok := ret.Get(0).(func(string) domain.User); ok {
-		r0 = rf(username)
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, username)
 	} else {
 		r0 = ret.Get(0).(domain.User)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(username)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, page, pageSize
+func (_m *UserRepository) List(ctx context.Context, page int, pageSize int) ([]domain.User, *database.Paginator, error) {
+	ret := _m.Called(ctx, page, pageSize)
+
+	var r0 []domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []domain.User); ok {
+		r0 = rf(ctx, page, pageSize)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	var r1 *database.Paginator
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) *database.Paginator); ok {
+		r1 = rf(ctx, page, pageSize)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*database.Paginator)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int, int) error); ok {
+		r2 = rf(ctx, page, pageSize)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListOffset provides a mock function with given fields: ctx, offset, limit
+func (_m *UserRepository) ListOffset(ctx context.Context, offset int, limit int) ([]domain.User, error) {
+	ret := _m.Called(ctx, offset, limit)
+
+	var r0 []domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []domain.User); ok {
+		r0 = rf(ctx, offset, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, offset, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -91,13 +148,13 @@ func (_m *UserRepository) FindByUsername(username string) (domain.User, error) {
 	return r0, r1
 }
 
-// Store provides a mock function with given fields: user
-func (_m *UserRepository) Store(user domain.User) error {
-	ret := _m.Called(user)
+// Store provides a mock function with given fields: ctx, entity
+func (_m *UserRepository) Store(ctx context.Context, entity *domain.User) error {
+	ret := _m.Called(ctx, entity)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(domain.User) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.User) error); ok {
+		r0 = rf(ctx, entity)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -105,13 +162,13 @@ func (_m *UserRepository) Store(user domain.User) error {
 	return r0
 }
 
-// Update provides a mock function with given fields: user
-func (_m *UserRepository) Update(user domain.User) error {
-	ret := _m.Called(user)
+// Update provides a mock function with given fields: ctx, id, entity
+func (_m *UserRepository) Update(ctx context.Context, id string, entity *domain.User) error {
+	ret := _m.Called(ctx, id, entity)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(domain.User) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, string, *domain.User) error); ok {
+		r0 = rf(ctx, id, entity)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	domain "github.com/alpakih/go-api/internal/domain"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -12,13 +14,13 @@ type UserService struct {
 	mock.Mock
 }
 
-// Delete provides a mock function with given fields: id
-func (_m *UserService) Delete(id string) error {
-	ret := _m.Called(id)
+// Delete provides a mock function with given fields: ctx, id
+func (_m *UserService) Delete(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -26,13 +28,13 @@ func (_m *UserService) Delete(id string) error {
 	return r0
 }
 
-// Fetch provides a mock function with given fields: limit, offset
-func (_m *UserService) Fetch(limit int, offset int) ([]domain.User, error) {
-	ret := _m.Called(limit, offset)
+// Fetch provides a mock function with given fields: ctx, limit, offset
+func (_m *UserService) Fetch(ctx context.Context, limit int, offset int) ([]domain.User, error) {
+	ret := _m.Called(ctx, limit, offset)
 
 	var r0 []domain.User
-	if rf, ok := ret.Get(0).(func(int, int) []domain.User); ok {
-		r0 = rf(limit, offset)
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []domain.User); ok {
+		r0 = rf(ctx, limit, offset)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]domain.User)
@@ -40,8 +42,8 @@ func (_m *UserService) Fetch(limit int, offset int) ([]domain.User, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int, int) error); ok {
-		r1 = rf(limit, offset)
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, limit, offset)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -49,20 +51,20 @@ func (_m *UserService) Fetch(limit int, offset int) ([]domain.User, error) {
 	return r0, r1
 }
 
-// GetByID provides a mock function with given fields: id
-func (_m *UserService) GetByID(id string) (domain.User, error) {
-	ret := _m.Called(id)
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *UserService) GetByID(ctx context.Context, id string) (domain.User, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 domain.User
-	if rf, ok := ret.Get(0).(func(string) domain.User); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(domain.User)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -70,20 +72,20 @@ func (_m *UserService) GetByID(id string) (domain.User, error) {
 	return r0, r1
 }
 
-// GetByUsername provides a mock function with given fields: username
-func (_m *UserService) GetByUsername(username string) (domain.User, error) {
-	ret := _m.Called(username)
+// GetByUsername provides a mock function with given fields: ctx, username
+func (_m *UserService) GetByUsername(ctx context.Context, username string) (domain.User, error) {
+	ret := _m.Called(ctx, username)
 
 	var r0 domain.User
-	if rf, ok := ret.Get(0).(func(string) domain.User); ok {
-		r0 = rf(username)
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, username)
 	} else {
 		r0 = ret.Get(0).(domain.User)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(username)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -91,13 +93,13 @@ func (_m *UserService) GetByUsername(username string) (domain.User, error) {
 	return r0, r1
 }
 
-// Store provides a mock function with given fields: user
-func (_m *UserService) Store(user domain.StoreRequest) error {
-	ret := _m.Called(user)
+// Store provides a mock function with given fields: ctx, user
+func (_m *UserService) Store(ctx context.Context, user domain.StoreRequest) error {
+	ret := _m.Called(ctx, user)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(domain.StoreRequest) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.StoreRequest) error); ok {
+		r0 = rf(ctx, user)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -105,13 +107,13 @@ func (_m *UserService) Store(user domain.StoreRequest) error {
 	return r0
 }
 
-// Update provides a mock function with given fields: user
-func (_m *UserService) Update(user domain.UpdateRequest) error {
-	ret := _m.Called(user)
+// Update provides a mock function with given fields: ctx, user
+func (_m *UserService) Update(ctx context.Context, user domain.UpdateRequest) error {
+	ret := _m.Called(ctx, user)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(domain.UpdateRequest) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UpdateRequest) error); ok {
+		r0 = rf(ctx, user)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -1,6 +1,11 @@
 package domain
 
 import (
+	"context"
+	"github.com/alpakih/go-api/pkg/crud"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/normalize"
+	"github.com/alpakih/go-api/pkg/validation"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
@@ -12,49 +17,107 @@ type User struct {
 	Password  string    `gorm:"column:password;type:varchar(100)" json:"-"`
 	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+
+	// UserNameCanonical normalized username used for lookups and uniqueness
+	UserNameCanonical string `gorm:"column:username_canonical;type:varchar(50);uniqueIndex:idx_users_username_canonical" json:"-"`
+
+	// Email encrypted at rest, looked up through its blind index
+	Email database.EncryptedString `gorm:"column:email;type:text" json:"email,omitempty"`
+	// EmailIndex unique among the users with an email, SQL Server allowing a single NULL
+	// in a unique index
+	EmailIndex *string `gorm:"column:email_index;type:varchar(64);uniqueIndex:idx_users_email_index,where:email_index IS NOT NULL" json:"-"`
 }
 
 func (c User) TableName() string {
 	return "users"
 }
 
-// BeforeCreate - Lifecycle callback - Generate UUID before persisting
+// BeforeCreate - Lifecycle callback - Generate UUID before persisting, unless already set
 func (c *User) BeforeCreate(tx *gorm.DB) (err error) {
-	c.ID = uuid.New().String()
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
 
 	return
 }
 
+// BeforeSave - Lifecycle callback - Keep the canonical username and the email blind index in sync
+func (c *User) BeforeSave(tx *gorm.DB) (err error) {
+	if c.UserName != "" {
+		c.UserNameCanonical = normalize.Username(c.UserName)
+	}
+	if c.Email != "" {
+		index, err := database.BlindIndex(normalize.Email(string(c.Email)))
+		if err != nil {
+			return err
+		}
+		c.EmailIndex = &index
+	}
+
+	return
+}
+
+// UserAggregate aggregate type of the user events published to the outbox.
+const UserAggregate = "user"
+
+// Types of the user events published to the outbox.
+const (
+	UserCreated = "user.created"
+	UserDeleted = "user.deleted"
+)
+
+// UserEvent payload of the user events.
+type UserEvent struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+func init() {
+	// columns of the unique and unique_update rules of the user requests
+	validation.AllowTable("users", "id", "username_canonical", "email_index")
+}
+
+// FetchRequest the users to fetch, the limit ones after skipping the offset first
+// ones, in the order of their ID.
+type FetchRequest struct {
+	Limit  int `query:"limit" default:"10" validate:"min=1,max=100"`
+	Offset int `query:"offset" default:"0" validate:"min=0"`
+}
+
+type IDRequest struct {
+	ID string `param:"id" validate:"required,uuid"`
+}
+
 type TokenRequest struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required,max=100"`
 }
 
 type StoreRequest struct {
-	Username string `json:"username" validate:"required,unique=username:users"`
-	Password string `json:"password" validate:"required,max=100"`
+	Username string `json:"username" validate:"required,max=50,normalized_max=username:50,unique=username_canonical:users:username"`
+	Password string `json:"password" validate:"required,max=100,password,password_username=Username"`
+	Email    string `json:"email" validate:"omitempty,email,max=254,unique=email_index:users:email_index"`
 }
 
 type UpdateRequest struct {
 	ID       string `json:"id" validate:"required"`
-	Username string `json:"username" validate:"required,max=50,unique_update=ID:users:username:id"`
-	Password string `json:"password" validate:"max=100"`
+	Username string `json:"username" validate:"required,max=50,normalized_max=username:50,unique_update=ID:users:username_canonical:id:username"`
+	Password string `json:"password" validate:"omitempty,max=100,password,password_username=Username"`
+	Email    string `json:"email" validate:"omitempty,email,max=254,unique_update=ID:users:email_index:id:email_index"`
 }
 
 type UserService interface {
-	Fetch(limit int, offset int) ([]User, error)
-	GetByID(id string) (User, error)
-	GetByUsername(username string) (User, error)
-	Update(user UpdateRequest) error
-	Store(user StoreRequest) error
-	Delete(id string) error
+	// Fetch return at most limit users, skipping the first offset ones.
+	Fetch(ctx context.Context, limit int, offset int) ([]User, error)
+	GetByID(ctx context.Context, id string) (User, error)
+	GetByUsername(ctx context.Context, username string) (User, error)
+	Update(ctx context.Context, user UpdateRequest) error
+	Store(ctx context.Context, user StoreRequest) error
+	Delete(ctx context.Context, id string) error
 }
 
 type UserRepository interface {
-	Fetch(limit int, offset int) ([]User, error)
-	FindByID(id string) (User, error)
-	FindByUsername(username string) (User, error)
-	Update(user User) error
-	Store(user User) error
-	Delete(id string) error
+	crud.Repository[User]
+	FindByUsername(ctx context.Context, username string) (User, error)
+	FindByEmail(ctx context.Context, email string) (User, error)
 }
@@ -3,16 +3,16 @@ package http
 import (
 	"errors"
 	"github.com/alpakih/go-api/internal/domain"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/intercept"
 	"github.com/alpakih/go-api/pkg/validation"
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/bcrypt"
-	"gopkg.in/go-playground/validator.v9"
 	"gorm.io/gorm"
 	"net/http"
-	"strconv"
 	"time"
 )
 
@@ -31,14 +31,12 @@ func (r *UserHandler) RequestToken(ctx echo.Context) error {
 		log.Error(err)
 		return ctx.JSON(http.StatusBadRequest, echo.Map{"message": http.StatusText(http.StatusBadRequest)})
 	}
-	if err := ctx.Validate(&request); err != nil {
+	if err := validation.ValidateRequest(ctx, &request); err != nil {
 		log.Error(err)
-		return ctx.JSON(http.StatusUnprocessableEntity,
-			echo.Map{"message": http.StatusText(http.StatusUnprocessableEntity),
-				"errors": validation.WrapValidationErrors(err.(validator.ValidationErrors))})
+		return validation.ErrorResponse(ctx, err)
 	}
 
-	result, err := r.UserService.GetByUsername(request.Username)
+	result, err := r.UserService.GetByUsername(ctx.Request().Context(), request.Username)
 	if err != nil {
 		log.Error(err)
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -57,6 +55,9 @@ func (r *UserHandler) RequestToken(ctx echo.Context) error {
 
 	tokenClaims["id"] = result.ID
 	tokenClaims["username"] = result.UserName
+	if tenant, ok := database.TenantFromContext(ctx.Request().Context()); ok {
+		tokenClaims[intercept.TenantClaim] = tenant.ID
+	}
 	tokenClaims["exp"] = time.Now().Add(time.Duration(viper.GetInt("auth.jwt.validity")) * time.Second).Unix()
 
 	//Encode Token
@@ -72,20 +73,13 @@ func (r *UserHandler) RequestToken(ctx echo.Context) error {
 }
 
 func (r *UserHandler) FetchUsers(ctx echo.Context) error {
-	params := ctx.QueryParams()
-
-	// default vars
-	limit := 10
-	offset := 0
-
-	if offsetParse, err := strconv.Atoi(params.Get("offset")); err == nil {
-		offset = offsetParse
-	}
-	if limitParse, err := strconv.Atoi(params.Get("limit")); err == nil {
-		limit = limitParse
+	var request domain.FetchRequest
+	if err := validation.BindParams(ctx, &request); err != nil {
+		log.Error(err)
+		return validation.ErrorResponse(ctx, err)
 	}
 
-	result, err := r.UserService.Fetch(limit, offset)
+	result, err := r.UserService.Fetch(ctx.Request().Context(), request.Limit, request.Offset)
 	if err != nil {
 		log.Error(err)
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"message": http.StatusText(http.StatusInternalServerError)})
@@ -94,9 +88,13 @@ func (r *UserHandler) FetchUsers(ctx echo.Context) error {
 }
 
 func (r *UserHandler) GetUserByID(ctx echo.Context) error {
-	param := ctx.Param("id")
+	var request domain.IDRequest
+	if err := validation.BindParams(ctx, &request); err != nil {
+		log.Error(err)
+		return validation.ErrorResponse(ctx, err)
+	}
 
-	result, err := r.UserService.GetByID(param)
+	result, err := r.UserService.GetByID(ctx.Request().Context(), request.ID)
 	if err != nil {
 		log.Error(err)
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -114,14 +112,12 @@ func (r *UserHandler) StoreUser(ctx echo.Context) error {
 		log.Error(err)
 		return ctx.JSON(http.StatusBadRequest, echo.Map{"message": http.StatusText(http.StatusBadRequest)})
 	}
-	if err := ctx.Validate(&request); err != nil {
+	if err := validation.ValidateRequest(ctx, &request); err != nil {
 		log.Error(err)
-		return ctx.JSON(http.StatusUnprocessableEntity,
-			echo.Map{"message": http.StatusText(http.StatusUnprocessableEntity),
-				"errors": validation.WrapValidationErrors(err.(validator.ValidationErrors))})
+		return validation.ErrorResponse(ctx, err)
 	}
 
-	if err := r.UserService.Store(request); err != nil {
+	if err := r.UserService.Store(ctx.Request().Context(), request); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"message": http.StatusText(http.StatusInternalServerError)})
 	}
 
@@ -135,14 +131,12 @@ func (r *UserHandler) UpdateUser(ctx echo.Context) error {
 		log.Error(err)
 		return ctx.JSON(http.StatusBadRequest, echo.Map{"message": http.StatusText(http.StatusBadRequest)})
 	}
-	if err := ctx.Validate(&request); err != nil {
+	if err := validation.ValidateRequest(ctx, &request); err != nil {
 		log.Error(err)
-		return ctx.JSON(http.StatusUnprocessableEntity,
-			echo.Map{"message": http.StatusText(http.StatusUnprocessableEntity),
-				"errors": validation.WrapValidationErrors(err.(validator.ValidationErrors))})
+		return validation.ErrorResponse(ctx, err)
 	}
 
-	if _, err := r.UserService.GetByID(request.ID); err != nil {
+	if _, err := r.UserService.GetByID(ctx.Request().Context(), request.ID); err != nil {
 		log.Error(err)
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ctx.JSON(http.StatusNotFound, echo.Map{"message": http.StatusText(http.StatusNotFound)})
@@ -150,7 +144,7 @@ func (r *UserHandler) UpdateUser(ctx echo.Context) error {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"message": http.StatusText(http.StatusInternalServerError)})
 	}
 
-	if err := r.UserService.Update(request); err != nil {
+	if err := r.UserService.Update(ctx.Request().Context(), request); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"message": http.StatusText(http.StatusInternalServerError)})
 	}
 
@@ -158,16 +152,20 @@ func (r *UserHandler) UpdateUser(ctx echo.Context) error {
 }
 
 func (r *UserHandler) DeleteUser(ctx echo.Context) error {
-	param := ctx.Param("id")
+	var request domain.IDRequest
+	if err := validation.BindParams(ctx, &request); err != nil {
+		log.Error(err)
+		return validation.ErrorResponse(ctx, err)
+	}
 
-	if _, err := r.UserService.GetByID(param); err != nil {
+	if _, err := r.UserService.GetByID(ctx.Request().Context(), request.ID); err != nil {
 		log.Error(err)
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ctx.JSON(http.StatusNotFound, echo.Map{"message": http.StatusText(http.StatusNotFound)})
 		}
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"message": http.StatusText(http.StatusInternalServerError)})
 	}
-	if err := r.UserService.Delete(param); err != nil {
+	if err := r.UserService.Delete(ctx.Request().Context(), request.ID); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"message": http.StatusText(http.StatusInternalServerError)})
 	}
 	return ctx.JSON(http.StatusOK, echo.Map{"message": "delete data success"})
@@ -1,34 +1,39 @@
 package http
 
 import (
+	"encoding/json"
 	"github.com/alpakih/go-api/internal/domain"
 	"github.com/alpakih/go-api/internal/domain/mocks"
-	"github.com/google/uuid"
+	"github.com/alpakih/go-api/internal/users/factory"
+	_userRepo "github.com/alpakih/go-api/internal/users/repository/relational"
+	_userService "github.com/alpakih/go-api/internal/users/service"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/database/databasetest"
+	"github.com/alpakih/go-api/pkg/outbox"
+	"github.com/alpakih/go-api/pkg/validation"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"strings"
 	"testing"
-	"time"
 )
 
 func TestGetByID(t *testing.T) {
-	id := uuid.New().String()
-	mockUser := domain.User{
-		ID:        id,
-		UserName:  "testing",
-		Password:  "password",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
+	users, err := database.Make[*domain.User](factory.NewUserFactory(), 1)
+	require.NoError(t, err)
+	mockUser := *users[0]
+	id := mockUser.ID
 
 	mockUCase := new(mocks.UserService)
 
-	mockUCase.On("GetByID", id).Return(mockUser, nil)
+	mockUCase.On("GetByID", mock.Anything, id).Return(mockUser, nil)
 
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req, err := http.NewRequest(echo.GET, "/api/v1/users/"+id, strings.NewReader(""))
 	assert.NoError(t, err)
 
@@ -46,3 +51,112 @@ func TestGetByID(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	mockUCase.AssertExpectations(t)
 }
+
+func TestGetByIDInvalidID(t *testing.T) {
+	mockUCase := new(mocks.UserService)
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(echo.GET, "/api/v1/users/1", nil), rec)
+	c.SetPath("api/v1/users/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	handler := UserHandler{UserService: mockUCase}
+	require.NoError(t, handler.GetUserByID(c))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"/id"`)
+	mockUCase.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestFetchUsers(t *testing.T) {
+	mockUCase := new(mocks.UserService)
+	mockUCase.On("Fetch", mock.Anything, 10, 0).Return([]domain.User{}, nil).Once()
+	mockUCase.On("Fetch", mock.Anything, 20, 40).Return([]domain.User{}, nil).Once()
+
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	handler := UserHandler{UserService: mockUCase}
+	fetch := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.FetchUsers(e.NewContext(httptest.NewRequest(echo.GET, "/api/v1/users?"+query, nil), rec)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, fetch("").Code, "defaults")
+	assert.Equal(t, http.StatusOK, fetch("limit=20&offset=40").Code)
+
+	rec := fetch("limit=abc&offset=-1")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var response struct {
+		Errors map[string][]validation.ErrorValidation `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Errors["/limit"], 1)
+	assert.Equal(t, "The limit must be a number.", response.Errors["/limit"][0].Message)
+	require.Len(t, response.Errors["/offset"], 1)
+	assert.Equal(t, "The offset must be at least 0.", response.Errors["/offset"][0].Message)
+	assert.Equal(t, http.StatusUnprocessableEntity, fetch("limit=500").Code)
+	mockUCase.AssertExpectations(t)
+}
+
+func TestFetchUsersIntegration(t *testing.T) {
+	db := databasetest.New(t, domain.User{})
+	users, err := database.CreateWith[*domain.User](db, factory.NewUserFactory(), 3)
+	require.NoError(t, err)
+	ids := []string{users[0].ID, users[1].ID, users[2].ID}
+	sort.Strings(ids)
+
+	handler := NewUserHandler(_userService.NewUserService(_userRepo.NewUserRepository(db)))
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	fetch := func(query string) []string {
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.FetchUsers(e.NewContext(httptest.NewRequest(echo.GET, "/api/v1/users?"+query, nil), rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		fetched := []string{}
+		for _, user := range response.Data {
+			fetched = append(fetched, user.ID)
+		}
+		return fetched
+	}
+
+	assert.Equal(t, ids, fetch(""), "the default offset starts at the first user")
+	assert.Equal(t, ids[:2], fetch("limit=2"))
+	assert.Equal(t, ids[1:], fetch("limit=2&offset=1"))
+	assert.Equal(t, ids[2:], fetch("limit=2&offset=2"))
+	assert.Empty(t, fetch("limit=2&offset=3"))
+}
+
+func TestStoreUserIntegration(t *testing.T) {
+	db := databasetest.New(t, domain.User{}, outbox.Event{})
+	handler := NewUserHandler(_userService.NewUserService(_userRepo.NewUserRepository(db)))
+
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	store := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(echo.POST, "/api/v1/users", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.StoreUser(e.NewContext(req, rec)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, store(`{"username":"Alice","password":"secret"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, store(`{"username":" alice ","password":"secret"}`).Code)
+
+	var count int64
+	require.NoError(t, db.Model(&domain.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	var events []outbox.Event
+	require.NoError(t, db.Find(&events).Error)
+	require.Len(t, events, 1)
+	assert.Equal(t, domain.UserCreated, events[0].Type)
+}
@@ -0,0 +1,71 @@
+package factory
+
+import (
+	"fmt"
+	"github.com/alpakih/go-api/internal/domain"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+	"sync"
+	"time"
+)
+
+// DefaultPassword plain password of the users generated by NewUserFactory.
+const DefaultPassword = "password"
+
+// Seed seed of the fake data provider used by NewUserFactory.
+const Seed = 42
+
+var (
+	passwordOnce sync.Once
+	passwordHash string
+
+	// usernames numbers the usernames of every factory, so that the users made
+	// by several factories of the same process don't collide.
+	usernames = database.NewSequence()
+)
+
+// NewUserFactory create a database.Factory generating domain.User records
+// with a unique fake username and DefaultPassword as password.
+//
+// Defined states:
+//  - "dormant": created and last updated more than a year ago
+//
+//  users, err := database.Create[*domain.User](factory.NewUserFactory(), 5)
+func NewUserFactory() *database.Factory {
+	return NewUserFactoryWithSeed(Seed)
+}
+
+// NewUserFactoryWithSeed same as NewUserFactory with the given seed, such as the
+// current time to generate other users on every run.
+func NewUserFactoryWithSeed(seed int64) *database.Factory {
+	fake := database.NewFaker(seed)
+
+	return database.NewFactory(func() interface{} {
+		now := time.Now()
+		return &domain.User{
+			ID:        uuid.New().String(),
+			UserName:  fmt.Sprintf("%s_%d", fake.Username(), usernames.Next()),
+			Password:  hashedDefaultPassword(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}).DefineState("dormant", func(record interface{}) {
+		user := record.(*domain.User)
+		user.CreatedAt = fake.Date(time.Now().AddDate(-3, 0, 0), time.Now().AddDate(-2, 0, 0))
+		user.UpdatedAt = fake.Date(user.CreatedAt, time.Now().AddDate(-1, 0, 0))
+	})
+}
+
+// hashedDefaultPassword hash DefaultPassword once, bcrypt being slow by design.
+func hashedDefaultPassword() string {
+	passwordOnce.Do(func() {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(DefaultPassword), viper.GetInt("app.bcryptCost"))
+		if err != nil {
+			panic(err)
+		}
+		passwordHash = string(bytes)
+	})
+	return passwordHash
+}
@@ -0,0 +1,128 @@
+package relational
+
+import (
+	"context"
+	"errors"
+	"github.com/alpakih/go-api/internal/domain"
+	"github.com/alpakih/go-api/internal/users/factory"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/database/databasetest"
+	_ "github.com/alpakih/go-api/pkg/database/dialect/all"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"os"
+	"testing"
+)
+
+// dialectDSNs environment variables holding the DSN of a test database per dialect,
+// such as TEST_POSTGRES_DSN="host=localhost user=postgres dbname=test sslmode=disable".
+// Dialects without one are skipped, sqlite always runs in memory.
+var dialectDSNs = map[string]string{
+	"mysql":    "TEST_MYSQL_DSN",
+	"postgres": "TEST_POSTGRES_DSN",
+	"mssql":    "TEST_MSSQL_DSN",
+}
+
+func TestUserRepositoryConformance(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testUserRepository(t, databasetest.New(t, domain.User{}))
+	})
+
+	for _, dialect := range []string{"mysql", "postgres", "mssql"} {
+		dialect := dialect
+		t.Run(dialect, func(t *testing.T) {
+			dsn := os.Getenv(dialectDSNs[dialect])
+			if dsn == "" {
+				t.Skipf("%s not set", dialectDSNs[dialect])
+			}
+			testUserRepository(t, databasetest.NewDSN(t, dialect, dsn, domain.User{}))
+		})
+	}
+}
+
+// testUserRepository the behaviour expected from the repository on every dialect,
+// given a migrated, empty database.
+func testUserRepository(t *testing.T, db *gorm.DB) {
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	users, err := database.CreateWith[*domain.User](db, factory.NewUserFactory(), 3)
+	require.NoError(t, err)
+
+	t.Run("store and find by username", func(t *testing.T) {
+		require.NoError(t, repo.Store(ctx, &domain.User{UserName: "Alice", Password: "secret"}))
+
+		found, err := repo.FindByUsername(ctx, " ALICE ")
+		require.NoError(t, err)
+		assert.Equal(t, "Alice", found.UserName)
+		assert.Equal(t, "alice", found.UserNameCanonical)
+
+		// in a savepoint, postgres aborting the whole transaction on error otherwise
+		assert.Error(t, database.WithinTransaction(ctx, func(ctx context.Context) error {
+			return repo.Store(ctx, &domain.User{UserName: "alice", Password: "secret"})
+		}))
+	})
+
+	t.Run("update keeps the canonical username in sync", func(t *testing.T) {
+		require.NoError(t, repo.Update(ctx, users[0].ID, &domain.User{UserName: "Bob"}))
+
+		found, err := repo.FindByID(ctx, users[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "bob", found.UserNameCanonical)
+		assert.Equal(t, users[0].Password, found.Password)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		records, paginator, err := repo.List(ctx, 1, 2)
+		require.NoError(t, err)
+		assert.Len(t, records, 2)
+		assert.Equal(t, int64(4), paginator.Total)
+		assert.Equal(t, int64(2), paginator.MaxPage)
+	})
+
+	t.Run("list offset", func(t *testing.T) {
+		all, err := repo.ListOffset(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, all, 4)
+
+		records, err := repo.ListOffset(ctx, 1, 2)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, all[1].ID, records[0].ID)
+		assert.Equal(t, all[2].ID, records[1].ID)
+
+		records, err = repo.ListOffset(ctx, 3, 2)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, all[3].ID, records[0].ID)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, repo.Delete(ctx, users[1].ID))
+
+		_, err := repo.FindByID(ctx, users[1].ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("joins the transaction of the context", func(t *testing.T) {
+		err := database.WithinTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, repo.Store(ctx, &domain.User{UserName: "carol", Password: "secret"}))
+			_, err := repo.FindByUsername(ctx, "carol")
+			require.NoError(t, err)
+			return errors.New("rolled back")
+		})
+		assert.EqualError(t, err, "rolled back")
+
+		_, err = repo.FindByUsername(ctx, "carol")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := repo.FindByID(cancelled, users[0].ID)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,51 @@
+// Package relational implements domain.UserRepository with GORM queries portable
+// across the dialects registered in pkg/database: mysql, postgres, mssql and sqlite.
+package relational
+
+import (
+	"context"
+	"github.com/alpakih/go-api/internal/domain"
+	"github.com/alpakih/go-api/pkg/crud"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/normalize"
+	"gorm.io/gorm"
+)
+
+// userRepo the generic CRUD repository of users, with their lookups by username and email.
+type userRepo struct {
+	*crud.GormRepository[domain.User]
+}
+
+// NewUserRepository will create an implementation of domain.UserRepository
+func NewUserRepository(db *gorm.DB) domain.UserRepository {
+	return &userRepo{
+		GormRepository: crud.NewRepository[domain.User](db),
+	}
+}
+
+func (m userRepo) FindByUsername(ctx context.Context, username string) (domain.User, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var entity domain.User
+	if err := database.FromContext(ctx, m.DB).First(&entity, "username_canonical =?", normalize.Username(username)).Error; err != nil {
+		return domain.User{}, err
+	}
+	return entity, nil
+}
+
+func (m userRepo) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	index, err := database.BlindIndex(normalize.Email(email))
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var entity domain.User
+	if err := database.FromContext(ctx, m.DB).First(&entity, "email_index =?", index).Error; err != nil {
+		return domain.User{}, err
+	}
+	return entity, nil
+}
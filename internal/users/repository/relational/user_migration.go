@@ -0,0 +1,68 @@
+package relational
+
+import (
+	"github.com/alpakih/go-api/internal/domain"
+	"github.com/alpakih/go-api/pkg/normalize"
+	"gorm.io/gorm"
+)
+
+// UsernameCollision a group of existing users whose usernames share the same canonical form.
+type UsernameCollision struct {
+	Canonical string
+	Users     []domain.User
+}
+
+// MigrateUsernameCanonical adds and backfills the username_canonical column of
+// existing users, then reports the usernames colliding once normalized.
+//
+// It must run before the auto migration of domain.User, which creates the
+// unique index on username_canonical and fails as long as collisions remain.
+func MigrateUsernameCanonical(db *gorm.DB) ([]UsernameCollision, error) {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&domain.User{}) {
+		return nil, nil
+	}
+	if !migrator.HasColumn(&domain.User{}, "UserNameCanonical") {
+		if err := migrator.AddColumn(&domain.User{}, "UserNameCanonical"); err != nil {
+			return nil, err
+		}
+	}
+
+	var batch []domain.User
+	if err := db.Select("id", "username").
+		Where("username_canonical IS NULL OR username_canonical = ?", "").
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, user := range batch {
+				if err := db.Session(&gorm.Session{NewDB: true}).Model(&domain.User{}).
+					Where("id = ?", user.ID).
+					UpdateColumn("username_canonical", normalize.Username(user.UserName)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	var canonicals []string
+	if err := db.Model(&domain.User{}).
+		Group("username_canonical").
+		Having("COUNT(*) > 1").
+		Pluck("username_canonical", &canonicals).Error; err != nil {
+		return nil, err
+	}
+
+	collisions := make([]UsernameCollision, 0, len(canonicals))
+	for _, canonical := range canonicals {
+		var users []domain.User
+		if err := db.Select("id", "username", "created_at").
+			Where("username_canonical = ?", canonical).
+			Order("created_at").
+			Find(&users).Error; err != nil {
+			return nil, err
+		}
+		collisions = append(collisions, UsernameCollision{Canonical: canonical, Users: users})
+	}
+
+	return collisions, nil
+}
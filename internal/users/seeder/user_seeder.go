@@ -0,0 +1,62 @@
+package seeder
+
+import (
+	"errors"
+	"github.com/alpakih/go-api/internal/domain"
+	"github.com/alpakih/go-api/internal/users/factory"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/normalize"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// AdminSeeder creates the administrator account configured by
+// "seed.admin.username" and "seed.admin.password" unless it already exists.
+func AdminSeeder() database.Seeder {
+	return database.Seeder{
+		Name: "admin",
+		Run: func(tx *gorm.DB) error {
+			username := viper.GetString("seed.admin.username")
+			password := viper.GetString("seed.admin.password")
+			if username == "" || password == "" {
+				return errors.New("seed.admin.username and seed.admin.password are required")
+			}
+
+			var count int64
+			if err := tx.Model(&domain.User{}).
+				Where("username_canonical = ?", normalize.Username(username)).
+				Count(&count).Error; err != nil || count > 0 {
+				return err
+			}
+
+			bytes, err := bcrypt.GenerateFromPassword([]byte(password), viper.GetInt("app.bcryptCost"))
+			if err != nil {
+				return err
+			}
+			return tx.Create(&domain.User{UserName: username, Password: string(bytes)}).Error
+		},
+	}
+}
+
+// FakeUserSeeder tops the users table up to "seed.users.count" generated users.
+// Only runs in the dev environment. The users are generated from a new seed on every
+// run, so they don't collide with those of the previous runs.
+func FakeUserSeeder() database.Seeder {
+	return database.Seeder{
+		Name:         "fake_users",
+		Order:        10,
+		DependsOn:    []string{"admin"},
+		Environments: []string{"dev"},
+		Run: func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&domain.User{}).Count(&count).Error; err != nil {
+				return err
+			}
+
+			_, err := factory.NewUserFactoryWithSeed(time.Now().UnixNano()).SaveWith(tx, viper.GetInt("seed.users.count")-int(count))
+			return err
+		},
+	}
+}
@@ -0,0 +1,33 @@
+package seeder
+
+import (
+	"github.com/alpakih/go-api/internal/domain"
+	"github.com/alpakih/go-api/pkg/database/databasetest"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFakeUserSeederRunsAgain(t *testing.T) {
+	db := databasetest.New(t, domain.User{})
+	viper.Set("seed.users.count", 5)
+	defer viper.Set("seed.users.count", nil)
+
+	seeder := FakeUserSeeder()
+	require.NoError(t, seeder.Run(db))
+
+	// deleted users are replaced by new ones, not by the same usernames again
+	require.NoError(t, db.Where("1 = 1").Limit(2).Delete(&domain.User{}).Error)
+	require.NoError(t, seeder.Run(db))
+	require.NoError(t, seeder.Run(db))
+
+	var count int64
+	require.NoError(t, db.Model(&domain.User{}).Count(&count).Error)
+	assert.Equal(t, int64(5), count)
+}
+
+func TestFakeUserSeederDependsOnAdmin(t *testing.T) {
+	assert.Equal(t, "admin", AdminSeeder().Name)
+	assert.Equal(t, []string{AdminSeeder().Name}, FakeUserSeeder().DependsOn)
+}
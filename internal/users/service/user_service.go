@@ -1,59 +1,95 @@
 package service
 
 import (
+	"context"
 	"github.com/alpakih/go-api/internal/domain"
+	"github.com/alpakih/go-api/pkg/crud"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/outbox"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
 )
 
 type userService struct {
 	userRepository domain.UserRepository
+	users          *crud.Service[domain.User]
 }
 
 // NewUserService will create new an userService object representation of domain.UserService interface
 func NewUserService(ur domain.UserRepository) domain.UserService {
 	return &userService{
 		userRepository: ur,
+		users: crud.NewService[domain.User](ur, crud.Hooks[domain.User]{
+			BeforeCreate: func(ctx context.Context, user *domain.User) error {
+				return hashPassword(user)
+			},
+			AfterCreate: func(ctx context.Context, user *domain.User) error {
+				return publish(ctx, user.ID, domain.UserCreated, domain.UserEvent{ID: user.ID, Username: user.UserName})
+			},
+			BeforeUpdate: func(ctx context.Context, id string, user *domain.User) error {
+				return hashPassword(user)
+			},
+			AfterDelete: func(ctx context.Context, id string) error {
+				return publish(ctx, id, domain.UserDeleted, domain.UserEvent{ID: id})
+			},
+		}),
 	}
 }
 
-func (u userService) Fetch(limit int, offset int) ([]domain.User, error) {
-	return u.userRepository.Fetch(limit, offset)
+func (u userService) Fetch(ctx context.Context, limit int, offset int) ([]domain.User, error) {
+	return u.users.ListOffset(ctx, offset, limit)
 }
 
-func (u userService) GetByID(id string) (domain.User, error) {
-	return u.userRepository.FindByID(id)
+func (u userService) GetByID(ctx context.Context, id string) (domain.User, error) {
+	return u.users.Get(ctx, id)
 }
 
-func (u userService) Update(user domain.UpdateRequest) error {
-	var entity domain.User
+func (u userService) Update(ctx context.Context, user domain.UpdateRequest) error {
+	entity := domain.User{
+		UserName: strings.TrimSpace(user.Username),
+		Password: user.Password,
+		Email:    database.EncryptedString(strings.TrimSpace(user.Email)),
+	}
+	return u.users.Update(ctx, user.ID, &entity)
+}
 
-	entity.ID = user.ID
-	entity.UserName = user.Username
-	if user.Password != "" {
-		entity.Password = user.Password
+func (u userService) Store(ctx context.Context, user domain.StoreRequest) error {
+	entity := domain.User{
+		UserName: strings.TrimSpace(user.Username),
+		Password: user.Password,
+		Email:    database.EncryptedString(strings.TrimSpace(user.Email)),
 	}
-	return u.userRepository.Update(entity)
+	return u.users.Create(ctx, &entity)
+}
 
+func (u userService) Delete(ctx context.Context, id string) error {
+	return u.users.Delete(ctx, id)
 }
 
-func (u userService) Store(user domain.StoreRequest) error {
-	if bytes, err := bcrypt.GenerateFromPassword([]byte(user.Password), viper.GetInt("app.bcryptCost"));
-	err != nil {
+// hashPassword replace the password of the user by its bcrypt hash, unless it is
+// left empty by an update.
+func hashPassword(user *domain.User) error {
+	if user.Password == "" {
+		return nil
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(user.Password), viper.GetInt("app.bcryptCost"))
+	if err != nil {
 		return err
-	} else {
-		entity := domain.User{
-			UserName: user.Username,
-			Password: string(bytes),
-		}
-		return u.userRepository.Store(entity)
 	}
+	user.Password = string(bytes)
+	return nil
 }
 
-func (u userService) Delete(id string) error {
-	return u.userRepository.Delete(id)
+// publish write a user event to the outbox, in the transaction of ctx.
+func publish(ctx context.Context, id, eventType string, payload domain.UserEvent) error {
+	event, err := outbox.New(domain.UserAggregate, id, eventType, payload)
+	if err != nil {
+		return err
+	}
+	return outbox.Publish(ctx, event)
 }
 
-func (u userService) GetByUsername(id string) (domain.User, error) {
-	return u.userRepository.FindByUsername(id)
+func (u userService) GetByUsername(ctx context.Context, id string) (domain.User, error) {
+	return u.userRepository.FindByUsername(ctx, id)
 }
\ No newline at end of file
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"github.com/alpakih/go-api/internal/domain"
 	"github.com/alpakih/go-api/internal/domain/mocks"
+	"github.com/alpakih/go-api/internal/users/factory"
+	"github.com/alpakih/go-api/pkg/database"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
@@ -11,17 +14,18 @@ import (
 
 func TestGetByID(t *testing.T) {
 	mockUserRepo := new(mocks.UserRepository)
-	mockUser := domain.User{
-		UserName: "testing",
-		Password: "123123",
+	users, err := database.Make[*domain.User](factory.NewUserFactory(), 1)
+	if err != nil {
+		t.Fatal(err)
 	}
+	mockUser := *users[0]
 
 	t.Run("success", func(t *testing.T) {
 		mockUserRepo.On("FindByID", mock.Anything, mock.AnythingOfType("string")).Return(mockUser, nil).Once()
 
 		u := NewUserService(mockUserRepo)
 
-		a, err := u.GetByID(mockUser.ID)
+		a, err := u.GetByID(context.Background(), mockUser.ID)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, a)
@@ -34,7 +38,7 @@ func TestGetByID(t *testing.T) {
 
 		u := NewUserService(mockUserRepo)
 
-		a, err := u.GetByID(mockUser.ID)
+		a, err := u.GetByID(context.Background(), mockUser.ID)
 
 		assert.Error(t, err)
 		assert.Equal(t, domain.User{}, a)
@@ -43,3 +47,22 @@ func TestGetByID(t *testing.T) {
 	})
 
 }
+
+func TestFetch(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	users, err := database.Make[*domain.User](factory.NewUserFactory(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockUserRepo.On("ListOffset", mock.Anything, 40, 20).Return([]domain.User{*users[0], *users[1]}, nil).Once()
+
+	u := NewUserService(mockUserRepo)
+
+	list, err := u.Fetch(context.Background(), 20, 40)
+
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	mockUserRepo.AssertExpectations(t)
+}
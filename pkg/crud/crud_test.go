@@ -0,0 +1,166 @@
+package crud_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/crud"
+	"github.com/alpakih/go-api/pkg/database/databasetest"
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type note struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title" validate:"required,max=20"`
+	Slug  string `json:"slug"`
+}
+
+type noteRequest struct {
+	Title string `json:"title" validate:"required"`
+}
+
+type noteUpdateRequest struct {
+	Title string `json:"title" validate:"omitempty,max=20"`
+}
+
+func newServer(t *testing.T) (*echo.Echo, *crud.Service[note]) {
+	db := databasetest.New(t, note{})
+	service := crud.NewService[note](crud.NewRepository[note](db), crud.Hooks[note]{
+		BeforeCreate: func(ctx context.Context, n *note) error {
+			n.Slug = strings.ToLower(n.Title)
+			return nil
+		},
+		BeforeUpdate: func(ctx context.Context, id string, n *note) error {
+			if n.Slug != "" {
+				return errors.New("the hook is given the stored record")
+			}
+			return nil
+		},
+		AfterDelete: func(ctx context.Context, id string) error {
+			if id == "2" {
+				return errors.New("protected")
+			}
+			return nil
+		},
+	})
+
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	crud.NewHandler(service,
+		crud.BindRequest(func(ctx context.Context, request noteRequest, n *note) error {
+			n.Title = request.Title
+			return nil
+		}),
+		crud.BindRequest(func(ctx context.Context, request noteUpdateRequest, n *note) error {
+			n.Title = request.Title
+			return nil
+		}),
+	).Register(e.Group("/api"), "/notes")
+	return e, service
+}
+
+func request(e *echo.Echo, method, path, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec, response
+}
+
+func TestHandler(t *testing.T) {
+	e, service := newServer(t)
+
+	rec, response := request(e, http.MethodPost, "/api/notes", `{"title":"First"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "title": "First", "slug": "first"}, response["data"])
+
+	rec, response = request(e, http.MethodPost, "/api/notes", `{"title":""}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.NotEmpty(t, response["errors"])
+
+	rec, _ = request(e, http.MethodPost, "/api/notes", `{"title":`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = request(e, http.MethodPost, "/api/notes", `{"title":"Second"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec, response = request(e, http.MethodGet, "/api/notes?page=2&pageSize=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	page := response["data"].(map[string]interface{})
+	assert.Equal(t, float64(2), page["Total"])
+	assert.Equal(t, float64(2), page["MaxPage"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": float64(2), "title": "Second", "slug": "second"}}, page["Records"])
+
+	rec, response = request(e, http.MethodGet, "/api/notes", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, float64(10), response["data"].(map[string]interface{})["PageSize"])
+	rec, response = request(e, http.MethodGet, "/api/notes?page=0&pageSize=500", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, response["errors"], "/page")
+	assert.Contains(t, response["errors"], "/pageSize")
+	rec, _ = request(e, http.MethodGet, "/api/notes?page=two", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec, response = request(e, http.MethodPut, "/api/notes/1", `{"id":2,"title":"Renamed","slug":"ignored"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "title": "Renamed", "slug": "first"}, response["data"])
+	renamed, err := service.Get(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", renamed.Title)
+	assert.Equal(t, "first", renamed.Slug, "fields missing from the body are kept")
+	second, err := service.Get(context.Background(), "2")
+	require.NoError(t, err)
+	assert.Equal(t, "Second", second.Title, "the ID of the body is ignored")
+
+	rec, _ = request(e, http.MethodGet, "/api/notes/42", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		rec, _ = request(e, method, "/api/notes/first", `{"title":"Renamed"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code, "%s of an ID of another type than the key", method)
+	}
+
+	rec, _ = request(e, http.MethodDelete, "/api/notes/2", "")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	_, err = service.Get(context.Background(), "2")
+	assert.NoError(t, err, "the delete is rolled back with its after hook")
+
+	rec, _ = request(e, http.MethodDelete, "/api/notes/1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec, _ = request(e, http.MethodGet, "/api/notes/1", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestBindRequest(t *testing.T) {
+	db := databasetest.New(t, note{})
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	crud.NewHandler(crud.NewService[note](crud.NewRepository[note](db), crud.Hooks[note]{}),
+		crud.BindRequest(func(ctx context.Context, request noteRequest, n *note) error {
+			n.Title = strings.TrimSpace(request.Title)
+			n.Slug = "from-request"
+			return nil
+		}),
+		crud.BindRequest(func(ctx context.Context, request noteUpdateRequest, n *note) error {
+			n.Title = request.Title
+			return nil
+		}),
+	).Register(e.Group("/v2"), "/notes")
+
+	rec, response := request(e, http.MethodPost, "/v2/notes", `{"title":" Spaced ","slug":"ignored"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "title": "Spaced", "slug": "from-request"}, response["data"])
+
+	rec, _ = request(e, http.MethodPost, "/v2/notes", `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
@@ -0,0 +1,177 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"gopkg.in/go-playground/validator.v9"
+	"gorm.io/gorm"
+)
+
+// Binder bind and validate a request into entity: a new record on create, and on
+// update a zero T receiving the changes, which are all the hooks are given.
+type Binder[T any] func(ctx echo.Context, entity *T) error
+
+// Handler the echo handlers of the operations of a Service, answering with the
+// standard envelope: the status text or a success message under "message", the
+// records under "data", and the validation errors under "errors".
+type Handler[T any] struct {
+	Service *Service[T]
+	// Param name of the path parameter holding the ID, "id" by default.
+	Param string
+	// BindCreate and BindUpdate bind the body of the create and update requests,
+	// usually made with BindRequest so that clients only set the fields of a request type.
+	BindCreate Binder[T]
+	BindUpdate Binder[T]
+}
+
+// ListRequest the query parameters of List, the page size being 10 by default and
+// at most 100.
+type ListRequest struct {
+	Page     int `query:"page" default:"1" validate:"min=1"`
+	PageSize int `query:"pageSize" default:"10" validate:"min=1,max=100"`
+}
+
+// NewHandler create a Handler of the given service with the default settings,
+// binding the create and update requests with the given binders.
+//
+//  handler := crud.NewHandler(service,
+//      crud.BindRequest(func(ctx context.Context, request domain.StoreArticleRequest, article *domain.Article) error { ... }),
+//      crud.BindRequest(func(ctx context.Context, request domain.UpdateArticleRequest, article *domain.Article) error { ... }))
+func NewHandler[T any](service *Service[T], bindCreate, bindUpdate Binder[T]) *Handler[T] {
+	return &Handler[T]{
+		Service:    service,
+		Param:      "id",
+		BindCreate: bindCreate,
+		BindUpdate: bindUpdate,
+	}
+}
+
+// BindRequest return a Binder binding and validating a request R, then copying it
+// into the record with apply.
+//
+//  crud.BindRequest(func(ctx context.Context, request domain.StoreArticleRequest, article *domain.Article) error {
+//      article.Title = request.Title
+//      return nil
+//  })
+func BindRequest[T, R any](apply func(ctx context.Context, request R, entity *T) error) Binder[T] {
+	return func(ctx echo.Context, entity *T) error {
+		var request R
+		if err := ctx.Bind(&request); err != nil {
+			return err
+		}
+		if err := validation.ValidateRequest(ctx, &request); err != nil {
+			return err
+		}
+		return apply(ctx.Request().Context(), request, entity)
+	}
+}
+
+// Register register the handlers on group under path:
+//
+//  GET    path             List
+//  GET    path/:id         Get
+//  POST   path             Create
+//  PUT    path/:id         Update
+//  DELETE path/:id         Delete
+func (h *Handler[T]) Register(group *echo.Group, path string, middleware ...echo.MiddlewareFunc) {
+	group.GET(path, h.List, middleware...)
+	group.GET(path+"/:"+h.Param, h.Get, middleware...)
+	group.POST(path, h.Create, middleware...)
+	group.PUT(path+"/:"+h.Param, h.Update, middleware...)
+	group.DELETE(path+"/:"+h.Param, h.Delete, middleware...)
+}
+
+// List answer the page of records given by the "page" and "pageSize" query parameters,
+// see ListRequest.
+func (h *Handler[T]) List(ctx echo.Context) error {
+	var request ListRequest
+	if err := validation.BindParams(ctx, &request); err != nil {
+		return h.error(ctx, err)
+	}
+
+	_, paginator, err := h.Service.List(ctx.Request().Context(), request.Page, request.PageSize)
+	if err != nil {
+		return h.error(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"message": http.StatusText(http.StatusOK), "data": paginator})
+}
+
+// Get answer the record of the ID path parameter.
+func (h *Handler[T]) Get(ctx echo.Context) error {
+	result, err := h.Service.Get(ctx.Request().Context(), ctx.Param(h.Param))
+	if err != nil {
+		return h.error(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"message": http.StatusText(http.StatusOK), "data": result})
+}
+
+// Create create a record from the request body.
+func (h *Handler[T]) Create(ctx echo.Context) error {
+	var entity T
+	if err := h.BindCreate(ctx, &entity); err != nil {
+		return h.error(ctx, err)
+	}
+	if err := h.Service.Create(ctx.Request().Context(), &entity); err != nil {
+		return h.error(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, echo.Map{"message": "save data success", "data": entity})
+}
+
+// Update update the record of the ID path parameter with the non-zero fields bound
+// from the request body, and answer the updated record.
+func (h *Handler[T]) Update(ctx echo.Context) error {
+	id := ctx.Param(h.Param)
+	if _, err := h.Service.Get(ctx.Request().Context(), id); err != nil {
+		return h.error(ctx, err)
+	}
+	var changes T
+	if err := h.BindUpdate(ctx, &changes); err != nil {
+		return h.error(ctx, err)
+	}
+	if err := h.Service.Update(ctx.Request().Context(), id, &changes); err != nil {
+		return h.error(ctx, err)
+	}
+	entity, err := h.Service.Get(ctx.Request().Context(), id)
+	if err != nil {
+		return h.error(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"message": "update data success", "data": entity})
+}
+
+// Delete delete the record of the ID path parameter.
+func (h *Handler[T]) Delete(ctx echo.Context) error {
+	id := ctx.Param(h.Param)
+	if _, err := h.Service.Get(ctx.Request().Context(), id); err != nil {
+		return h.error(ctx, err)
+	}
+	if err := h.Service.Delete(ctx.Request().Context(), id); err != nil {
+		return h.error(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"message": "delete data success"})
+}
+
+// error answer err in the standard envelope: 400 for a malformed request, 422 for
+// validation errors, 404 for a missing record and 500 otherwise.
+func (h *Handler[T]) error(ctx echo.Context, err error) error {
+	log.Error(err)
+
+	var validationErrors validator.ValidationErrors
+	var paramErrors validation.ParamErrors
+	var ruleError *validation.RuleError
+	if errors.As(err, &validationErrors) || errors.As(err, &paramErrors) || errors.As(err, &ruleError) {
+		return validation.ErrorResponse(ctx, err)
+	}
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) && httpError.Code < http.StatusInternalServerError {
+		return ctx.JSON(httpError.Code, echo.Map{"message": http.StatusText(httpError.Code)})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.JSON(http.StatusNotFound, echo.Map{"message": http.StatusText(http.StatusNotFound)})
+	}
+	return ctx.JSON(http.StatusInternalServerError, echo.Map{"message": http.StatusText(http.StatusInternalServerError)})
+}
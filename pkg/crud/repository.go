@@ -0,0 +1,167 @@
+// Package crud provides the list, get, create, update and delete operations of a
+// GORM model as a generic repository, service and echo handler, so that a domain
+// only implements what is special about it:
+//
+//  repository := crud.NewRepository[domain.Article](db)
+//  service := crud.NewService[domain.Article](repository, crud.Hooks[domain.Article]{
+//      BeforeCreate: func(ctx context.Context, article *domain.Article) error {
+//          article.Slug = slug.Make(article.Title)
+//          return nil
+//      },
+//  })
+//  crud.NewHandler(service, bindCreate, bindUpdate).Register(v1, "/articles")
+package crud
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/alpakih/go-api/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Repository the records of the model T, identified by their primary key.
+type Repository[T any] interface {
+	List(ctx context.Context, page, pageSize int) ([]T, *database.Paginator, error)
+	ListOffset(ctx context.Context, offset, limit int) ([]T, error)
+	FindByID(ctx context.Context, id string) (T, error)
+	Store(ctx context.Context, entity *T) error
+	Update(ctx context.Context, id string, entity *T) error
+	Delete(ctx context.Context, id string) error
+}
+
+// GormRepository implementation of Repository with GORM. Its queries join the
+// transaction or tenant of their context, and are bounded by the query timeout.
+type GormRepository[T any] struct {
+	DB *gorm.DB
+	// Scopes applied to the listed records, to filter or sort them.
+	Scopes []func(db *gorm.DB) *gorm.DB
+}
+
+// NewRepository create a GormRepository of the model T.
+func NewRepository[T any](db *gorm.DB) *GormRepository[T] {
+	return &GormRepository[T]{DB: db}
+}
+
+// List return the records of the given page, along with the page information.
+func (r *GormRepository[T]) List(ctx context.Context, page, pageSize int) ([]T, *database.Paginator, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	records := []T{}
+	paginator := database.NewPaginator(database.FromContext(ctx, r.DB).Scopes(r.Scopes...), page, pageSize, &records)
+	if err := paginator.Find().Error; err != nil {
+		return nil, nil, err
+	}
+	return records, paginator, nil
+}
+
+// ListOffset return at most limit records, skipping the first offset ones. They are
+// ordered by primary key after the order of the scopes, so that the offsets are stable.
+func (r *GormRepository[T]) ListOffset(ctx context.Context, offset, limit int) ([]T, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	db := database.FromContext(ctx, r.DB)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	query := db.Scopes(r.Scopes...)
+	if field := stmt.Schema.PrioritizedPrimaryField; field != nil {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}})
+	}
+
+	records := []T{}
+	if err := query.Offset(offset).Limit(limit).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// FindByID return the record of the given primary key, gorm.ErrRecordNotFound if there is none.
+func (r *GormRepository[T]) FindByID(ctx context.Context, id string) (T, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var entity T
+	db := database.FromContext(ctx, r.DB)
+	primaryKey, key, err := r.primaryKey(db, id)
+	if err != nil {
+		return entity, err
+	}
+	if err := db.First(&entity, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: primaryKey.DBName}, Value: key}).Error; err != nil {
+		var zero T
+		return zero, err
+	}
+	return entity, nil
+}
+
+// Store insert the record, setting its generated fields such as its ID.
+func (r *GormRepository[T]) Store(ctx context.Context, entity *T) error {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return database.FromContext(ctx, r.DB).Create(entity).Error
+}
+
+// Update update the non-zero fields of the record of the given primary key,
+// whatever the primary key of entity.
+func (r *GormRepository[T]) Update(ctx context.Context, id string, entity *T) error {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	db := database.FromContext(ctx, r.DB)
+	primaryKey, key, err := r.primaryKey(db, id)
+	if err != nil {
+		return err
+	}
+	if err := primaryKey.Set(reflect.ValueOf(entity).Elem(), key); err != nil {
+		return err
+	}
+	return db.Updates(entity).Error
+}
+
+// Delete delete the record of the given primary key.
+func (r *GormRepository[T]) Delete(ctx context.Context, id string) error {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	db := database.FromContext(ctx, r.DB)
+	primaryKey, key, err := r.primaryKey(db, id)
+	if err != nil {
+		return err
+	}
+	return db.Delete(new(T), clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: primaryKey.DBName}, Value: key}).Error
+}
+
+// primaryKey return the primary key field of T, from the schema cache of db, and id
+// converted to its type. An id of another type, such as a name given for an integer
+// key, matches no record and gives gorm.ErrRecordNotFound.
+func (r *GormRepository[T]) primaryKey(db *gorm.DB, id string) (*schema.Field, interface{}, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, nil, err
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil, nil, fmt.Errorf("%s has no primary key", stmt.Schema.Name)
+	}
+
+	var key interface{} = id
+	var err error
+	switch field.IndirectFieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		key, err = strconv.ParseInt(id, 10, field.IndirectFieldType.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		key, err = strconv.ParseUint(id, 10, field.IndirectFieldType.Bits())
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s %q: %w", stmt.Schema.Name, id, gorm.ErrRecordNotFound)
+	}
+	return field, key, nil
+}
@@ -0,0 +1,101 @@
+package crud
+
+import (
+	"context"
+
+	"github.com/alpakih/go-api/pkg/database"
+)
+
+// Hooks functions called around the writes of a Service, all optional. The before
+// hooks run ahead of the transaction, so that slow work such as hashing a password
+// does not hold a connection, and can change the record or abort the write with an
+// error. The after hooks run in the transaction of the write, rolling it back with
+// an error, and are where the events of the outbox are published.
+type Hooks[T any] struct {
+	BeforeCreate func(ctx context.Context, entity *T) error
+	AfterCreate  func(ctx context.Context, entity *T) error
+	BeforeUpdate func(ctx context.Context, id string, entity *T) error
+	AfterUpdate  func(ctx context.Context, id string, entity *T) error
+	BeforeDelete func(ctx context.Context, id string) error
+	AfterDelete  func(ctx context.Context, id string) error
+}
+
+// Service the operations of the model T, its writes run in a transaction along with their hooks.
+type Service[T any] struct {
+	Repository Repository[T]
+	Hooks      Hooks[T]
+}
+
+// NewService create a Service of the records of the given repository.
+func NewService[T any](repository Repository[T], hooks Hooks[T]) *Service[T] {
+	return &Service[T]{Repository: repository, Hooks: hooks}
+}
+
+// List return the records of the given page, along with the page information.
+func (s *Service[T]) List(ctx context.Context, page, pageSize int) ([]T, *database.Paginator, error) {
+	return s.Repository.List(ctx, page, pageSize)
+}
+
+// ListOffset return at most limit records, skipping the first offset ones.
+func (s *Service[T]) ListOffset(ctx context.Context, offset, limit int) ([]T, error) {
+	return s.Repository.ListOffset(ctx, offset, limit)
+}
+
+// Get return the record of the given ID, gorm.ErrRecordNotFound if there is none.
+func (s *Service[T]) Get(ctx context.Context, id string) (T, error) {
+	return s.Repository.FindByID(ctx, id)
+}
+
+// Create store a new record.
+func (s *Service[T]) Create(ctx context.Context, entity *T) error {
+	if s.Hooks.BeforeCreate != nil {
+		if err := s.Hooks.BeforeCreate(ctx, entity); err != nil {
+			return err
+		}
+	}
+	return database.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.Repository.Store(ctx, entity); err != nil {
+			return err
+		}
+		if s.Hooks.AfterCreate != nil {
+			return s.Hooks.AfterCreate(ctx, entity)
+		}
+		return nil
+	})
+}
+
+// Update update the non-zero fields of the record of the given ID.
+func (s *Service[T]) Update(ctx context.Context, id string, entity *T) error {
+	if s.Hooks.BeforeUpdate != nil {
+		if err := s.Hooks.BeforeUpdate(ctx, id, entity); err != nil {
+			return err
+		}
+	}
+	return database.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.Repository.Update(ctx, id, entity); err != nil {
+			return err
+		}
+		if s.Hooks.AfterUpdate != nil {
+			return s.Hooks.AfterUpdate(ctx, id, entity)
+		}
+		return nil
+	})
+}
+
+// Delete delete the record of the given ID.
+func (s *Service[T]) Delete(ctx context.Context, id string) error {
+	if s.Hooks.BeforeDelete != nil {
+		if err := s.Hooks.BeforeDelete(ctx, id); err != nil {
+			return err
+		}
+	}
+	return database.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.Repository.Delete(ctx, id); err != nil {
+			return err
+		}
+		if s.Hooks.AfterDelete != nil {
+			return s.Hooks.AfterDelete(ctx, id)
+		}
+		return nil
+	})
+}
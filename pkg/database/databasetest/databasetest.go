@@ -0,0 +1,125 @@
+// Package databasetest provides isolated, migrated databases to integration tests,
+// backed by in-memory SQLite databases or, with NewDSN, by a real database server.
+//
+//  func TestStore(t *testing.T) {
+//      db := databasetest.New(t, domain.User{})
+//      repo := relational.NewUserRepository(db)
+//      ...
+//  }
+package databasetest
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/database"
+	_ "github.com/alpakih/go-api/pkg/database/dialect/sqlite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	counter     int64
+	unsafeChars = regexp.MustCompile(`\W+`)
+)
+
+// New open an in-memory SQLite database private to the test, auto migrate the
+// given models and the models registered in the database package, and return
+// a transaction on it.
+//
+// The transaction becomes the connection returned by database.GetConnection.
+// When the test ends the transaction is rolled back, the database is closed
+// and the database package singletons are reset.
+func New(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	return begin(t, Open(t, models...))
+}
+
+// NewDSN same as New on the database server of the given dialect, which must be
+// registered, for example by importing pkg/database/dialect/all.
+// Tables are created if needed and left in place, only the rows written by the
+// test being rolled back.
+func NewDSN(t testing.TB, dialect, dsn string, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	dialector, err := database.Dialector(dialect, dsn)
+	if err != nil {
+		t.Fatalf("databasetest: %s", err)
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("databasetest: open database: %s", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("databasetest: %s", err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+
+	migrate(t, db, models)
+	return begin(t, db)
+}
+
+// begin start the transaction wrapping a test.
+func begin(t testing.TB, db *gorm.DB) *gorm.DB {
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("databasetest: begin transaction: %s", tx.Error)
+	}
+	database.SetConnection(tx)
+
+	t.Cleanup(func() {
+		tx.Rollback()
+		database.Reset()
+	})
+
+	return tx
+}
+
+// migrate auto migrate the given models and the models registered in the database package.
+func migrate(t testing.TB, db *gorm.DB, models []interface{}) {
+	models = append(models, database.GetRegisteredModels()...)
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("databasetest: migrate: %s", err)
+	}
+}
+
+// Open same as New but return the database itself, without wrapping the test in
+// a transaction. Use it when the code under test needs to commit, the database
+// being dropped at the end of the test anyway.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	name := fmt.Sprintf("%s_%d", unsafeChars.ReplaceAllString(t.Name(), "_"), atomic.AddInt64(&counter, 1))
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared&_foreign_keys=1"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("databasetest: open database: %s", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("databasetest: %s", err)
+	}
+	// a single connection keeps the in-memory database alive and avoids
+	// table locks between connections of the shared cache
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+
+	migrate(t, db, models)
+
+	database.SetConnection(db)
+	t.Cleanup(func() {
+		database.Reset()
+		_ = sqlDB.Close()
+	})
+
+	return db
+}
@@ -1,12 +1,15 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"github.com/labstack/gommon/log"
 	"github.com/spf13/viper"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-	"strconv"
-	"strings"
+	"net/url"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,47 +19,99 @@ type Initializer func(*gorm.DB)
 type DialectInitializer func(dsn string) gorm.Dialector
 
 type dialect struct {
-	template    string
+	build       DSNBuilder
 	initializer DialectInitializer
+	// name of the dialect, set by connectionDialect.
+	name string
 }
 
+// connection an open named connection and its read replicas.
+type connection struct {
+	db       *gorm.DB
+	replicas *replicaSet
+	// dialect name of the registered dialect of the connection, resolved when opened.
+	dialect string
+}
+
+// pendingConnection a connection being opened, the callers asking for it meanwhile
+// waiting for done.
+type pendingConnection struct {
+	done chan struct{}
+	// wait whether the database is waited for, see waitForDatabase.
+	wait bool
+	conn *connection
+	err  error
+}
+
+// DefaultConnection name of the connection configured by the "database.*" keys.
+// Other connections are configured under "database.connections.{name}".
+const DefaultConnection = "default"
+
 var (
 	mu           sync.Mutex
-	dbConnection *gorm.DB
+	connections  = map[string]*connection{}
+	opening      = map[string]*pendingConnection{}
 	initializers []Initializer
-	models       []interface{}
-	dialects = map[string]dialect{}
+	models       = map[string][]interface{}{}
+	dialects     = map[string]dialect{}
+
+	// gormDialects dialects of the GORM dialectors not named after their dialect.
+	gormDialects = map[string]string{"sqlserver": "mssql"}
 
-	optionPlaceholders = map[string]string{
-		"{username}": "database.username",
-		"{password}": "database.password",
-		"{host}":     "database.host",
-		"{name}":     "database.name",
-		"{options}":  "database.options",
+	// inheritedSettings settings a named connection takes from the
+	// default connection when it does not define them.
+	inheritedSettings = map[string]bool{
+		"maxOpenConnections":         true,
+		"maxIdleConnections":         true,
+		"maxLifetime":                true,
+		"replicaPolicy":              true,
+		"replicaHealthCheckInterval": true,
+		"migrations.lockTimeout":     true,
+		"retry.timeout":              true,
+		"retry.initialInterval":      true,
+		"retry.maxInterval":          true,
+		"transaction.maxRetries":     true,
+		"transaction.retryInterval":  true,
+		"log.level":                  true,
+		"log.slowThreshold":          true,
+		"log.nPlusOneThreshold":      true,
 	}
 )
 
+// RegisterModel register a model of the default connection, migrated by Migrate.
 func RegisterModel(model interface{}) {
-	models = append(models, model)
+	RegisterNamedModel(DefaultConnection, model)
+}
+
+// RegisterNamedModel register a model of the given connection, migrated by Migrate.
+func RegisterNamedModel(connection string, model interface{}) {
+	models[connection] = append(models[connection], model)
 }
 
+// GetRegisteredModels return the models registered for the default connection.
 func GetRegisteredModels() []interface{} {
-	return append(make([]interface{}, 0, len(models)), models...)
+	return GetNamedRegisteredModels(DefaultConnection)
 }
 
-// ClearRegisteredModels unregister all models.
-func ClearRegisteredModels() {
-	models = []interface{}{}
+// GetNamedRegisteredModels return the models registered for the given connection.
+func GetNamedRegisteredModels(connection string) []interface{} {
+	return append(make([]interface{}, 0, len(models[connection])), models[connection]...)
 }
 
+// ClearRegisteredModels unregister all models, of every connection.
+func ClearRegisteredModels() {
+	models = map[string][]interface{}{}
+	sharedModelTypes = map[reflect.Type]bool{}
+}
 
-func RegisterDialect(name, template string, initializer DialectInitializer) {
+// RegisterDialect register a dialect, its DSN builder and its GORM dialector.
+func RegisterDialect(name string, build DSNBuilder, initializer DialectInitializer) {
 	mu.Lock()
 	defer mu.Unlock()
 	if _, ok := dialects[name]; ok {
 		panic(fmt.Sprintf("Dialect %q already exists", name))
 	}
-	dialects[name] = dialect{template, initializer}
+	dialects[name] = dialect{build: build, initializer: initializer}
 }
 
 func AddInitializer(initializer Initializer) {
@@ -68,88 +123,394 @@ func ClearInitializers() {
 	initializers = []Initializer{}
 }
 
-func GetConnection() *gorm.DB {
+// Dialector return the GORM dialector of the given registered dialect for a DSN.
+func Dialector(name, dsn string) (gorm.Dialector, error) {
 	mu.Lock()
 	defer mu.Unlock()
-	if dbConnection == nil {
-		dbConnection = newConnection()
+	dialect, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("dialect %q not supported, forgotten import?", name)
+	}
+	return dialect.initializer(dsn), nil
+}
+
+// GetConnection return the default connection, opening it on first use.
+func GetConnection() (*gorm.DB, error) {
+	return GetNamedConnection(DefaultConnection)
+}
+
+// GetNamedConnection return the connection configured under
+// "database.connections.{name}", opening it on first use.
+// Opening waits for the database to be reachable, see waitForDatabase.
+func GetNamedConnection(name string) (*gorm.DB, error) {
+	conn, err := openConnection(context.Background(), name, true)
+	if err != nil {
+		return nil, err
+	}
+	return conn.db, nil
+}
+
+// openConnection return the named connection, opening it if needed. The connection
+// is opened outside of mu, not to hold up the other connections, the concurrent
+// callers asking for it waiting for the first one to open it. Unless wait is set,
+// the database is pinged once with ctx instead of being waited for.
+func openConnection(ctx context.Context, name string, wait bool) (*connection, error) {
+	for {
+		mu.Lock()
+		if conn, ok := connections[name]; ok {
+			mu.Unlock()
+			return conn, nil
+		}
+		pending, ok := opening[name]
+		if !ok {
+			pending = &pendingConnection{done: make(chan struct{}), wait: wait}
+			opening[name] = pending
+		}
+		mu.Unlock()
+
+		if !ok {
+			pending.conn, pending.err = newConnection(ctx, name, wait)
+			mu.Lock()
+			delete(opening, name)
+			if pending.err == nil {
+				if conn, ok := connections[name]; ok {
+					// replaced by SetNamedConnection in the meantime
+					pending.conn.close()
+					pending.conn = conn
+				} else {
+					connections[name] = pending.conn
+				}
+			}
+			mu.Unlock()
+			close(pending.done)
+			return pending.conn, pending.err
+		}
+
+		select {
+		case <-pending.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// a failed attempt without waiting does not fail the callers willing to wait
+		if pending.err == nil || pending.wait || !wait {
+			return pending.conn, pending.err
+		}
 	}
-	return dbConnection
 }
 
 // Conn alias for GetConnection.
-func Conn() *gorm.DB {
+func Conn() (*gorm.DB, error) {
 	return GetConnection()
 }
 
+// SetConnection replace the connection returned by GetConnection,
+// for example with a transaction in tests.
+func SetConnection(db *gorm.DB) {
+	SetNamedConnection(DefaultConnection, db)
+}
+
+// SetNamedConnection replace the connection returned by GetNamedConnection.
+func SetNamedConnection(name string, db *gorm.DB) {
+	dialect := db.Dialector.Name()
+	if registered, ok := gormDialects[dialect]; ok {
+		dialect = registered
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	connections[name] = &connection{db: db, dialect: dialect}
+}
+
+// ConnectionNames return the name of the default connection followed by
+// the names of the connections configured under "database.connections".
+func ConnectionNames() []string {
+	names := []string{DefaultConnection}
+	var named []string
+	for name := range viper.GetStringMap("database.connections") {
+		named = append(named, name)
+	}
+	sort.Strings(named)
+	return append(names, named...)
+}
+
 // Close the database connections if they exist.
 func Close() error {
 	var err error = nil
 	mu.Lock()
 	defer mu.Unlock()
-	if dbConnection != nil {
-		db, _ := dbConnection.DB()
-		err = db.Close()
-		dbConnection = nil
+	for name, conn := range connections {
+		if closeErr := conn.close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(connections, name)
 	}
 
 	return err
 }
 
-// Migrate migrates all registered models.
-func Migrate() {
-	db := GetConnection()
-	for _, model := range models {
+// close the connection and its replicas.
+func (c *connection) close() error {
+	var err error
+	if db, dbErr := c.db.DB(); dbErr == nil {
+		err = db.Close()
+	}
+	if c.replicas != nil {
+		if closeErr := c.replicas.close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Reset forget the connections without closing them and the keyring, unregister all models,
+// initializers, Go migrations and seeders. Registered dialects are kept.
+// Used to isolate tests from each other.
+func Reset() {
+	mu.Lock()
+	connections = map[string]*connection{}
+	keyring = nil
+	mu.Unlock()
+
+	ClearRegisteredModels()
+	ClearInitializers()
+	ClearRegisteredMigrations()
+	ClearRegisteredSeeders()
+}
+
+// Migrate migrates all registered models, on their connection.
+func Migrate() error {
+	var names []string
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := MigrateNamed(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateNamed migrates the models registered for the given connection.
+// With SchemaPerTenant, the default connection migrates the shared models in the
+// public schema and the others in the schema of every tenant.
+func MigrateNamed(name string) error {
+	db, err := GetNamedConnection(name)
+	if err != nil {
+		return err
+	}
+	perTenant := name == DefaultConnection && SchemaPerTenant()
+	if perTenant {
+		if err := db.AutoMigrate(&Tenant{}); err != nil {
+			return fmt.Errorf("database connection %s: migrate tenants: %w", name, err)
+		}
+	}
+	for _, model := range models[name] {
+		if perTenant && !sharedModelTypes[modelType(model)] {
+			continue
+		}
 		if err := db.AutoMigrate(model); err != nil {
-			panic(err)
+			return fmt.Errorf("database connection %s: migrate %T: %w", name, model, err)
 		}
 	}
+	if perTenant {
+		return ForEachTenant(context.Background(), autoMigrateTenant)
+	}
+	return nil
 }
 
-func newConnection() *gorm.DB {
+// settingKey return the configuration key of a setting of the given connection.
+func settingKey(name, key string) string {
+	if name == DefaultConnection {
+		return "database." + key
+	}
+	named := "database.connections." + name + "." + key
+	if inheritedSettings[key] && !viper.IsSet(named) {
+		return "database." + key
+	}
+	return named
+}
 
-	driver := viper.GetString("database.connection")
+// DialectName return the dialect of the given connection, given by the scheme of
+// its URL if any. The dialect of an open connection is the one it was opened with,
+// that of a connection whose URL cannot be read is empty.
+func DialectName(name string) string {
+	mu.Lock()
+	conn, ok := connections[name]
+	mu.Unlock()
+	if ok {
+		return conn.dialect
+	}
+	dialect, _ := resolveDialect(name)
+	return dialect
+}
 
-	logLevel := logger.Silent
-	if viper.GetBool("app.debug") {
-		logLevel = logger.Info
+// resolveDialect read the dialect of the given connection from its settings.
+func resolveDialect(name string) (string, error) {
+	rawURL, err := secretSetting(nil, name, "url")
+	if err != nil {
+		return "", err
+	}
+	if rawURL != "" {
+		if u, err := url.Parse(rawURL); err == nil && u.Scheme != "" {
+			return urlDialect(u.Scheme), nil
+		}
 	}
+	return viper.GetString(settingKey(name, "connection")), nil
+}
 
-	dialect, ok := dialects[driver]
+// connectionDialect return the registered dialect of the given connection.
+func connectionDialect(name string) (dialect, error) {
+	driver, err := resolveDialect(name)
+	if err != nil {
+		return dialect{}, fmt.Errorf("database connection %s: %w", name, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	registered, ok := dialects[driver]
 	if !ok {
-		panic(fmt.Sprintf("DB Connection %s not supported, forgotten import?", driver))
+		return dialect{}, fmt.Errorf("database connection %s: dialect %q not supported, forgotten import?", name, driver)
+	}
+	registered.name = driver
+	return registered, nil
+}
+
+// newConnection open the named connection, waiting for the database to be
+// reachable when wait is set, pinging it once with ctx otherwise.
+func newConnection(ctx context.Context, name string, wait bool) (*connection, error) {
+	dialect, err := connectionDialect(name)
+	if err != nil {
+		return nil, err
 	}
 
-	dsn := dialect.buildDSN()
+	dsn, err := dialect.buildDSN(name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("database connection %s: %w", name, err)
+	}
+	queryLogger := NewLogger(name)
 	db, err := gorm.Open(dialect.initializer(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel), PrepareStmt: true,
+		Logger: queryLogger, PrepareStmt: true,
 	})
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("database connection %s: %w", name, err)
 	}
 
 	sqlDb, err := db.DB()
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("database connection %s: %w", name, err)
+	}
+	if err := db.Use(queryLogger); err != nil {
+		sqlDb.Close()
+		return nil, fmt.Errorf("database connection %s: %w", name, err)
 	}
 
-	sqlDb.SetMaxOpenConns(viper.GetInt("database.maxOpenConnections"))
-	sqlDb.SetMaxIdleConns(viper.GetInt("database.maxIdleConnections"))
-	sqlDb.SetConnMaxLifetime(time.Duration(viper.GetInt("database.maxLifetime")) * time.Second)
+	sqlDb.SetMaxOpenConns(viper.GetInt(settingKey(name, "maxOpenConnections")))
+	sqlDb.SetMaxIdleConns(viper.GetInt(settingKey(name, "maxIdleConnections")))
+	sqlDb.SetConnMaxLifetime(time.Duration(viper.GetInt(settingKey(name, "maxLifetime"))) * time.Second)
+
+	if wait {
+		err = waitForDatabase(name, sqlDb)
+	} else if err = sqlDb.PingContext(ctx); err != nil {
+		err = fmt.Errorf("database connection %s: %w", name, err)
+	}
+	if err != nil {
+		sqlDb.Close()
+		return nil, err
+	}
+
+	replicas, err := useReplicas(db, dialect, name)
+	if err != nil {
+		sqlDb.Close()
+		return nil, fmt.Errorf("database connection %s: %w", name, err)
+	}
+	if replicas != nil && name == DefaultConnection && SchemaPerTenant() {
+		// the resolver would send the queries of a tenant to other connections
+		replicas.close()
+		sqlDb.Close()
+		return nil, fmt.Errorf("database connection %s: schema per tenant does not support read replicas", name)
+	}
 
 	for _, initializer := range initializers {
 		initializer(db)
 	}
 
-	return db
+	return &connection{db: db, replicas: replicas, dialect: dialect.name}, nil
 }
 
-func (d dialect) buildDSN() string {
-	connStr := d.template
-	for k, v := range optionPlaceholders {
-		connStr = strings.Replace(connStr, k, viper.GetString(v), 1)
+// waitForDatabase ping the database until it answers, waiting between attempts
+// from "retry.initialInterval" up to "retry.maxInterval" milliseconds, doubling
+// each time, for at most "retry.timeout" seconds. A zero timeout pings only once.
+func waitForDatabase(name string, sqlDb *sql.DB) error {
+	timeout := time.Duration(viper.GetInt(settingKey(name, "retry.timeout"))) * time.Second
+	interval := time.Duration(viper.GetInt(settingKey(name, "retry.initialInterval"))) * time.Millisecond
+	maxInterval := time.Duration(viper.GetInt(settingKey(name, "retry.maxInterval"))) * time.Millisecond
+	deadline := time.Now().Add(timeout)
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
 	}
-	connStr = strings.Replace(connStr, "{port}", strconv.Itoa(viper.GetInt("database.port")), 1)
 
-	return connStr
+	for attempt := 1; ; attempt++ {
+		err := sqlDb.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if interval <= 0 || time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("database connection %s unreachable after %d attempts: %w", name, attempt, err)
+		}
+
+		log.Warnf("database connection %s unreachable, retrying in %s: %s", name, interval, err)
+		time.Sleep(interval)
+		if interval *= 2; maxInterval > 0 && interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// Ping check that the open connections, or the default one when none
+// is open yet, answer. Used by health checks, it does not wait for the
+// database as GetConnection does.
+func Ping(ctx context.Context) error {
+	mu.Lock()
+	open := make(map[string]*gorm.DB, len(connections))
+	for name, conn := range connections {
+		open[name] = conn.db
+	}
+	mu.Unlock()
+
+	if len(open) == 0 {
+		conn, err := openConnection(ctx, DefaultConnection, false)
+		if err != nil {
+			return err
+		}
+		open[DefaultConnection] = conn.db
+	}
+
+	for name, db := range open {
+		sqlDb, err := db.DB()
+		if err != nil {
+			return fmt.Errorf("database connection %s: %w", name, err)
+		}
+		if err := sqlDb.PingContext(ctx); err != nil {
+			return fmt.Errorf("database connection %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// WithQueryTimeout return ctx bounded by the "database.queryTimeout" setting,
+// in milliseconds, so a single query cannot outlive it. A zero timeout only
+// keeps the deadline of ctx, such as the request deadline.
+//
+//  ctx, cancel := database.WithQueryTimeout(ctx)
+//  defer cancel()
+func WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(viper.GetInt("database.queryTimeout")) * time.Millisecond
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
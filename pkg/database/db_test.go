@@ -0,0 +1,164 @@
+package database_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpakih/go-api/pkg/database"
+	_ "github.com/alpakih/go-api/pkg/database/dialect/sqlite"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type legacyNote struct {
+	ID   uint
+	Body string
+}
+
+func TestNamedConnections(t *testing.T) {
+	dir, err := ioutil.TempDir("", "connections")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	viper.Set("database.connection", "sqlite")
+	viper.Set("database.name", filepath.Join(dir, "default.db"))
+	viper.Set("database.maxOpenConnections", 3)
+	viper.Set("database.migrations.dir", filepath.Join(dir, "migrations"))
+	viper.Set("database.connections", map[string]interface{}{
+		"legacy": map[string]interface{}{"connection": "sqlite", "name": filepath.Join(dir, "legacy.db")},
+	})
+	defer viper.Reset()
+	defer database.Reset()
+	defer database.Close()
+
+	assert.Equal(t, []string{database.DefaultConnection, "legacy"}, database.ConnectionNames())
+
+	database.RegisterModel(note{})
+	database.RegisterNamedModel("legacy", legacyNote{})
+	require.NoError(t, database.Migrate())
+
+	db, err := database.GetConnection()
+	require.NoError(t, err)
+	legacy, err := database.GetNamedConnection("legacy")
+	require.NoError(t, err)
+	assert.NotSame(t, db, legacy)
+	again, err := database.GetNamedConnection("legacy")
+	require.NoError(t, err)
+	assert.Same(t, legacy, again)
+
+	// the dialect of an open connection is not read from the settings again
+	viper.Set("database.connections.legacy.url", "postgres://app@db.local/legacy")
+	assert.Equal(t, "sqlite", database.DialectName("legacy"))
+
+	assert.True(t, db.Migrator().HasTable(&note{}))
+	assert.False(t, db.Migrator().HasTable(&legacyNote{}))
+	assert.True(t, legacy.Migrator().HasTable(&legacyNote{}))
+	assert.False(t, legacy.Migrator().HasTable(&note{}))
+
+	// pool settings not defined by the named connection are inherited
+	sqlDB, err := legacy.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 3, sqlDB.Stats().MaxOpenConnections)
+
+	assert.Equal(t, filepath.Join(dir, "migrations", "legacy"), database.MigrationsDir("legacy"))
+	database.RegisterMigration(database.Migration{Version: 1, Name: "default_only"})
+	database.RegisterMigration(database.Migration{Version: 2, Name: "legacy_only", Connection: "legacy"})
+
+	migrator, err := database.NamedMigrator("legacy")
+	require.NoError(t, err)
+	require.Len(t, migrator.Migrations(), 1)
+	assert.Equal(t, "legacy_only", migrator.Migrations()[0].Name)
+}
+
+func TestGetConnectionErrors(t *testing.T) {
+	defer viper.Reset()
+	defer database.Reset()
+
+	viper.Set("database.connection", "unknown")
+	_, err := database.GetConnection()
+	assert.EqualError(t, err, `database connection default: dialect "unknown" not supported, forgotten import?`)
+
+	viper.Set("database.connection", "sqlite")
+	viper.Set("database.name", filepath.Join(os.TempDir(), "missing", "directory", "default.db"))
+	_, err = database.GetConnection()
+	assert.Error(t, err)
+
+	database.RegisterModel(note{})
+	assert.Error(t, database.Migrate())
+}
+
+func TestGetConnectionRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the database becomes reachable once its directory exists
+	viper.Set("database.connection", "sqlite")
+	viper.Set("database.name", filepath.Join(dir, "later", "default.db"))
+	viper.Set("database.retry.timeout", 5)
+	viper.Set("database.retry.initialInterval", 10)
+	viper.Set("database.retry.maxInterval", 40)
+	viper.Set("database.maxIdleConnections", 1)
+	defer viper.Reset()
+	defer database.Reset()
+	defer database.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		os.Mkdir(filepath.Join(dir, "later"), 0755)
+	}()
+
+	_, err = database.GetConnection()
+	require.NoError(t, err)
+	require.NoError(t, database.Ping(context.Background()))
+
+	stats := database.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, database.DefaultConnection, stats[0].Connection)
+	assert.Equal(t, 1, stats[0].Open)
+}
+
+func TestOpeningDoesNotHoldUpOthers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opening")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the default database stays unreachable while GetConnection waits for it
+	viper.Set("database.connection", "sqlite")
+	viper.Set("database.name", filepath.Join(dir, "missing", "default.db"))
+	viper.Set("database.retry.timeout", 1)
+	viper.Set("database.retry.initialInterval", 10)
+	viper.Set("database.retry.maxInterval", 10)
+	viper.Set("database.connections", map[string]interface{}{
+		"legacy": map[string]interface{}{"connection": "sqlite", "name": filepath.Join(dir, "legacy.db")},
+	})
+	defer viper.Reset()
+	defer database.Reset()
+	defer database.Close()
+
+	start := time.Now()
+	assert.Error(t, database.Ping(context.Background()))
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond), "Ping does not wait for the database")
+
+	waited := make(chan error, 1)
+	go func() {
+		_, err := database.GetConnection()
+		waited <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, database.Ping(ctx), context.DeadlineExceeded, "Ping gives up with its context")
+
+	_, err = database.GetNamedConnection("legacy")
+	require.NoError(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond), "other connections open meanwhile")
+
+	assert.Error(t, <-waited)
+}
@@ -0,0 +1,13 @@
+// Package all registers every database dialect, so the dialect of a connection
+// can be chosen at runtime with "database.connection".
+//
+//  import _ "github.com/alpakih/go-api/pkg/database/dialect/all"
+//
+// The sqlite dialect requires cgo and is only registered when cgo is enabled.
+package all
+
+import (
+	_ "github.com/alpakih/go-api/pkg/database/dialect/mssql"
+	_ "github.com/alpakih/go-api/pkg/database/dialect/mysql"
+	_ "github.com/alpakih/go-api/pkg/database/dialect/postgres"
+)
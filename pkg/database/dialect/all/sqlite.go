@@ -0,0 +1,8 @@
+//go:build cgo
+// +build cgo
+
+package all
+
+import (
+	_ "github.com/alpakih/go-api/pkg/database/dialect/sqlite"
+)
@@ -1,10 +1,135 @@
 package mssql
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/alpakih/go-api/pkg/database"
 	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/migrator"
+	"net"
+	"net/url"
+	"time"
 )
 
 func init() {
-	database.RegisterDialect("mssql", "sqlserver://{username}:{password}@{host}:{port}?database={name}&{options}", sqlserver.Open)
+	database.RegisterDialect("mssql", BuildDSN, Open)
+	database.RegisterMigrationLocker("mssql", lockMigrations)
+}
+
+// BuildDSN build a sqlserver:// URL. The driver does not support client certificates,
+// and verifies the host name of the server with both verify-ca and verify-full.
+func BuildDSN(config database.DSNConfig) (string, error) {
+	if config.TLS.Cert != "" {
+		return "", errors.New("TLS client certificates are not supported by the mssql driver")
+	}
+
+	query := url.Values{}
+	if config.Name != "" {
+		query.Set("database", config.Name)
+	}
+	switch config.TLS.Mode {
+	case "disable":
+		query.Set("encrypt", "disable")
+	case "require":
+		query.Set("encrypt", "true")
+		query.Set("TrustServerCertificate", "true")
+	case "verify-ca", "verify-full":
+		query.Set("encrypt", "true")
+		if config.TLS.CA != "" {
+			query.Set("certificate", config.TLS.CA)
+		}
+		if config.TLS.ServerName != "" {
+			query.Set("hostNameInCertificate", config.TLS.ServerName)
+		}
+	}
+	for key, values := range config.Params {
+		query[key] = values
+	}
+
+	u := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(config.Username, config.Password),
+		Host:     config.Host,
+		RawQuery: query.Encode(),
+	}
+	if config.Port != "" {
+		u.Host = net.JoinHostPort(config.Host, config.Port)
+	}
+	if config.Instance != "" {
+		u.Path = "/" + config.Instance
+	}
+	if config.Options != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += config.Options
+	}
+	return u.String(), nil
+}
+
+// Dialector the sqlserver dialector, creating the filtered indexes of the models: SQL
+// Server allows a single NULL in a unique index, so unique nullable columns need
+// a "where:column IS NOT NULL" index option.
+type Dialector struct {
+	*sqlserver.Dialector
+}
+
+// Open return the Dialector of a sqlserver:// DSN.
+func Open(dsn string) gorm.Dialector {
+	return Dialector{sqlserver.Open(dsn).(*sqlserver.Dialector)}
+}
+
+func (dialector Dialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return Migrator{dialector.Dialector.Migrator(db).(sqlserver.Migrator)}
+}
+
+// Migrator the sqlserver migrator, adding the WHERE clause of the indexes it creates.
+type Migrator struct {
+	sqlserver.Migrator
+}
+
+func (m Migrator) CreateIndex(value interface{}, name string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		idx := stmt.Schema.LookIndex(name)
+		if idx == nil {
+			return fmt.Errorf("failed to create index with name %s", name)
+		}
+		opts := m.DB.Migrator().(migrator.BuildIndexOptionsInterface).BuildIndexOptions(idx.Fields, stmt)
+		values := []interface{}{clause.Column{Name: idx.Name}, m.CurrentTable(stmt), opts}
+
+		createIndexSQL := "CREATE "
+		if idx.Class != "" {
+			createIndexSQL += idx.Class + " "
+		}
+		createIndexSQL += "INDEX ? ON ??"
+		if idx.Where != "" {
+			createIndexSQL += " WHERE " + idx.Where
+		}
+		if idx.Option != "" {
+			createIndexSQL += " " + idx.Option
+		}
+		return m.DB.Exec(createIndexSQL, values...).Error
+	})
+}
+
+// lockMigrations uses an application lock owned by the session.
+func lockMigrations(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) (func() error, error) {
+	var result int
+	if err := conn.QueryRowContext(ctx, `DECLARE @result int;
+EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2;
+SELECT @result`, name, timeout.Milliseconds()).Scan(&result); err != nil {
+		return nil, err
+	}
+	if result < 0 {
+		return nil, database.ErrMigrationLocked
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", name)
+		return err
+	}, nil
 }
@@ -0,0 +1,29 @@
+package mssql
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+type account struct {
+	ID    string  `gorm:"primaryKey"`
+	Email *string `gorm:"uniqueIndex:idx_accounts_email,where:email IS NOT NULL"`
+}
+
+func TestCreateFilteredIndex(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(Dialector{sqlserver.New(sqlserver.Config{Conn: conn}).(*sqlserver.Dialector)}, &gorm.Config{})
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE UNIQUE INDEX "idx_accounts_email" ON "accounts"("email") WHERE email IS NOT NULL`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, db.Migrator().CreateIndex(&account{}, "idx_accounts_email"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
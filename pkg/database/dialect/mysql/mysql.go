@@ -1,10 +1,84 @@
 package mysql
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"github.com/alpakih/go-api/pkg/database"
+	driver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
+	"net"
+	"strings"
+	"time"
 )
 
 func init() {
-	database.RegisterDialect("mysql", "{username}:{password}@({host}:{port})/{name}?{options}", mysql.Open)
+	database.RegisterDialect("mysql", BuildDSN, mysql.Open)
+	database.RegisterMigrationLocker("mysql", lockMigrations)
+	database.RegisterRetryClassifier("mysql", isRetryable)
+}
+
+// BuildDSN build a go-sql-driver DSN, registering the TLS configuration of the
+// connection with the driver when TLS is enabled.
+func BuildDSN(config database.DSNConfig) (string, error) {
+	port := config.Port
+	if port == "" {
+		port = "3306"
+	}
+	cfg := driver.NewConfig()
+	cfg.User = config.Username
+	cfg.Passwd = config.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(config.Host, port)
+	cfg.DBName = config.Name
+
+	tlsConfig, err := config.TLS.Config(config.Host)
+	if err != nil {
+		return "", err
+	}
+	if tlsConfig != nil {
+		// one configuration per server, replicas possibly having their own name
+		cfg.TLSConfig = "go-api-" + cfg.Addr
+		if err := driver.RegisterTLSConfig(cfg.TLSConfig, tlsConfig); err != nil {
+			return "", err
+		}
+	}
+
+	dsn := cfg.FormatDSN()
+	for _, options := range []string{config.Options, config.Params.Encode()} {
+		if options == "" {
+			continue
+		}
+		if strings.Contains(dsn, "?") {
+			dsn += "&" + options
+		} else {
+			dsn += "?" + options
+		}
+	}
+	if _, err := driver.ParseDSN(dsn); err != nil {
+		return "", err
+	}
+	return dsn, nil
+}
+
+// isRetryable tells whether err is a deadlock (1213) or a lock wait timeout (1205).
+func isRetryable(err error) bool {
+	var mysqlErr *driver.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205)
+}
+
+// lockMigrations uses a named lock, released automatically if the session ends.
+func lockMigrations(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) (func() error, error) {
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, int(timeout.Seconds())).Scan(&acquired); err != nil {
+		return nil, err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return nil, database.ErrMigrationLocked
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+		return err
+	}, nil
 }
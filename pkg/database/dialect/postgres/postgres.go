@@ -1,10 +1,98 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"github.com/alpakih/go-api/pkg/database"
+	"github.com/jackc/pgconn"
 	"gorm.io/driver/postgres"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
 )
 
 func init() {
-	database.RegisterDialect("postgres", "host={host} port={port} user={username} dbname={name} password={password} {options}", postgres.Open)
-}
\ No newline at end of file
+	database.RegisterDialect("postgres", BuildDSN, postgres.Open)
+	database.RegisterMigrationLocker("postgres", lockMigrations)
+	database.RegisterRetryClassifier("postgres", isRetryable)
+}
+
+// BuildDSN build a keyword/value DSN, quoting the values as libpq does.
+// The TLS settings map to sslmode, sslrootcert, sslcert and sslkey.
+func BuildDSN(config database.DSNConfig) (string, error) {
+	var pairs []string
+	add := func(key, value string) {
+		if value != "" {
+			pairs = append(pairs, key+"="+quote(value))
+		}
+	}
+	add("host", config.Host)
+	add("port", config.Port)
+	add("user", config.Username)
+	add("password", config.Password)
+	add("dbname", config.Name)
+	add("sslmode", config.TLS.Mode)
+	add("sslrootcert", config.TLS.CA)
+	add("sslcert", config.TLS.Cert)
+	add("sslkey", config.TLS.Key)
+	if config.Options != "" {
+		pairs = append(pairs, config.Options)
+	}
+
+	keys := make([]string, 0, len(config.Params))
+	for key := range config.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add(key, config.Params.Get(key))
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// quote a value containing spaces, quotes or backslashes.
+func quote(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// isRetryable tells whether err is a serialization failure (40001) or a deadlock (40P01).
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
+
+// lockMigrations uses a session-level advisory lock whose key is derived from the lock name.
+func lockMigrations(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) (func() error, error) {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(name))
+	key := int64(hash.Sum64())
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, database.ErrMigrationLocked
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		return err
+	}, nil
+}
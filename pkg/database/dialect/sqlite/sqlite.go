@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/alpakih/go-api/pkg/database"
+	"gorm.io/driver/sqlite"
+	"strings"
+	"time"
+)
+
+func init() {
+	database.RegisterDialect("sqlite", BuildDSN, sqlite.Open)
+	database.RegisterMigrationLocker("sqlite", lockMigrations)
+}
+
+// BuildDSN build a file: URI of the database named by the "name" setting.
+func BuildDSN(config database.DSNConfig) (string, error) {
+	dsn := "file:" + strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(config.Name)
+	var options []string
+	if config.Options != "" {
+		options = append(options, config.Options)
+	}
+	if len(config.Params) > 0 {
+		options = append(options, config.Params.Encode())
+	}
+	if len(options) > 0 {
+		dsn += "?" + strings.Join(options, "&")
+	}
+	return dsn, nil
+}
+
+// lockMigrations is a no-op, SQLite locks the whole database file while
+// a migration transaction writes to it.
+func lockMigrations(_ context.Context, _ *sql.Conn, _ string, _ time.Duration) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,337 @@
+package database
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// ErrNoBlindIndexKey returned when computing a blind index without "encryption.blindIndexKey".
+var ErrNoBlindIndexKey = errors.New("encryption.blindIndexKey is not configured")
+
+// Keyring the versioned master keys encrypting the data keys of EncryptedString values.
+//
+// Values are encrypted with a random data key, itself encrypted, or wrapped,
+// with the current master key and stored along with the value. Values encrypted
+// with an older master key are still decrypted, until RotateEncryption
+// re-encrypts them with the current one.
+type Keyring struct {
+	current    int
+	keys       map[int][]byte
+	blindIndex []byte
+}
+
+var keyring *Keyring
+
+// NewKeyring create a keyring encrypting with the key of the current version.
+// Keys are 32 bytes long, for AES-256. The optional blind index key is used by BlindIndex.
+func NewKeyring(current int, keys map[int][]byte, blindIndexKey []byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("encryption key %d not found", current)
+	}
+	for version, key := range keys {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("encryption key %d is not set, generate one with \"go-api encryption keygen\"", version)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %d must be 32 bytes long, not %d", version, len(key))
+		}
+	}
+	return &Keyring{current: current, keys: keys, blindIndex: blindIndexKey}, nil
+}
+
+// LoadKeyring create the keyring configured by "encryption.currentKey",
+// "encryption.keys", a map of versions to base64 encoded keys, and "encryption.blindIndexKey".
+//
+//  "encryption": {"currentKey": 2, "keys": {"1": "...", "2": "..."}, "blindIndexKey": "..."}
+func LoadKeyring() (*Keyring, error) {
+	keys := map[int][]byte{}
+	for version, encoded := range viper.GetStringMapString("encryption.keys") {
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key version %q", version)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %d: %w", v, err)
+		}
+		keys[v] = key
+	}
+
+	var blindIndexKey []byte
+	if encoded := viper.GetString("encryption.blindIndexKey"); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption.blindIndexKey: %w", err)
+		}
+		blindIndexKey = key
+	}
+
+	return NewKeyring(viper.GetInt("encryption.currentKey"), keys, blindIndexKey)
+}
+
+// GetKeyring return the keyring set with SetKeyring, loading it with LoadKeyring on first use.
+func GetKeyring() (*Keyring, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if keyring == nil {
+		k, err := LoadKeyring()
+		if err != nil {
+			return nil, err
+		}
+		keyring = k
+	}
+	return keyring, nil
+}
+
+// SetKeyring replace the keyring used by EncryptedString and BlindIndex, nil
+// making the next use load it from the configuration again.
+func SetKeyring(k *Keyring) {
+	mu.Lock()
+	defer mu.Unlock()
+	keyring = k
+}
+
+// GenerateKey return a new random key, base64 encoded as in the configuration.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Encrypt encrypt plaintext with a new data key wrapped with the current key,
+// as "v{version}:{wrapped data key}:{ciphertext}".
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.current], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d:%s:%s", k.current,
+		base64.StdEncoding.EncodeToString(wrapped), base64.StdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// Decrypt decrypt a value returned by Encrypt, with any version of the keys.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "v") {
+		return "", errors.New("malformed encrypted value")
+	}
+	version, err := strconv.Atoi(parts[0][1:])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	key, ok := k.keys[version]
+	if !ok {
+		return "", fmt.Errorf("encryption key %d not found", version)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	dataKey, err := open(key, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex return the keyed hash of value, allowing exact lookups and uniqueness
+// checks on an encrypted column through an indexed column holding the hash.
+// Values should be normalized first, such as lower cased emails.
+func (k *Keyring) BlindIndex(value string) (string, error) {
+	if len(k.blindIndex) == 0 {
+		return "", ErrNoBlindIndexKey
+	}
+	mac := hmac.New(sha256.New, k.blindIndex)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// BlindIndex return the blind index of value with the keyring of GetKeyring.
+func BlindIndex(value string) (string, error) {
+	k, err := GetKeyring()
+	if err != nil {
+		return "", err
+	}
+	return k.BlindIndex(value)
+}
+
+// seal encrypt with AES-GCM, the random nonce prefixing the ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptedString a string encrypted at rest with the keyring of GetKeyring.
+// The value is held in clear in memory and in JSON. An empty string is stored as NULL.
+//
+//  Email database.EncryptedString `gorm:"column:email;type:text" json:"email"`
+type EncryptedString string
+
+var encryptedStringType = reflect.TypeOf(EncryptedString(""))
+
+// Value implements driver.Valuer, encrypting the string.
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return nil, nil
+	}
+	k, err := GetKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return k.Encrypt(string(s))
+}
+
+// Scan implements sql.Scanner, decrypting the stored value.
+func (s *EncryptedString) Scan(value interface{}) error {
+	if value == nil {
+		*s = ""
+		return nil
+	}
+	stored, err := cast.ToStringE(value)
+	if err != nil {
+		return err
+	}
+	if stored == "" {
+		*s = ""
+		return nil
+	}
+	k, err := GetKeyring()
+	if err != nil {
+		return err
+	}
+	plaintext, err := k.Decrypt(stored)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// RotateEncryption re-encrypt with the current key the EncryptedString columns of
+// the given models encrypted with an older key, and return the number of values
+// re-encrypted. Each batch of values is updated in its own transaction, so the
+// rotation can be interrupted and run again.
+func RotateEncryption(ctx context.Context, db *gorm.DB, models ...interface{}) (int, error) {
+	k, err := GetKeyring()
+	if err != nil {
+		return 0, err
+	}
+	current := fmt.Sprintf("v%d:%%", k.current)
+
+	rotated := 0
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return rotated, err
+		}
+		primaryKey := stmt.Schema.PrioritizedPrimaryField
+		if primaryKey == nil {
+			return rotated, fmt.Errorf("%s has no primary key", stmt.Schema.Name)
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.FieldType != encryptedStringType || field.DBName == "" {
+				continue
+			}
+			for {
+				var rows []map[string]interface{}
+				if err := db.WithContext(ctx).Table(stmt.Table).
+					Select(primaryKey.DBName, field.DBName).
+					Where(field.DBName+" IS NOT NULL AND "+field.DBName+" <> ? AND "+field.DBName+" NOT LIKE ?", "", current).
+					Order(primaryKey.DBName).
+					Limit(500).
+					Find(&rows).Error; err != nil {
+					return rotated, err
+				}
+				if len(rows) == 0 {
+					break
+				}
+
+				if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+					for _, row := range rows {
+						plaintext, err := k.Decrypt(cast.ToString(row[field.DBName]))
+						if err != nil {
+							return fmt.Errorf("%s.%s of %v: %w", stmt.Table, field.DBName, row[primaryKey.DBName], err)
+						}
+						encrypted, err := k.Encrypt(plaintext)
+						if err != nil {
+							return err
+						}
+						if err := tx.Table(stmt.Table).
+							Where(primaryKey.DBName+" = ?", row[primaryKey.DBName]).
+							UpdateColumn(field.DBName, encrypted).Error; err != nil {
+							return err
+						}
+					}
+					return nil
+				}); err != nil {
+					return rotated, err
+				}
+				rotated += len(rows)
+			}
+		}
+	}
+	return rotated, nil
+}
@@ -0,0 +1,91 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/database/databasetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type secret struct {
+	ID    uint
+	Value database.EncryptedString `gorm:"type:text"`
+}
+
+func newKeyring(t *testing.T, current int) *database.Keyring {
+	keys := map[int][]byte{1: bytes.Repeat([]byte{1}, 32), 2: bytes.Repeat([]byte{2}, 32)}
+	k, err := database.NewKeyring(current, keys, []byte("blind index key"))
+	require.NoError(t, err)
+	return k
+}
+
+func TestKeyring(t *testing.T) {
+	v1, v2 := newKeyring(t, 1), newKeyring(t, 2)
+
+	encrypted, err := v1.Encrypt("alice@example.com")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "v1:"))
+	assert.NotContains(t, encrypted, "alice")
+
+	again, err := v1.Encrypt("alice@example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again, "each value has its own data key and nonce")
+
+	decrypted, err := v2.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", decrypted, "older keys still decrypt")
+
+	tampered := encrypted[:len(encrypted)-4] + "AAA="
+	_, err = v1.Decrypt(tampered)
+	assert.Error(t, err)
+
+	_, err = database.NewKeyring(1, map[int][]byte{1: []byte("short")}, nil)
+	assert.Error(t, err)
+
+	index, err := v1.BlindIndex("alice@example.com")
+	require.NoError(t, err)
+	other, err := v2.BlindIndex("alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, index, other)
+	assert.Len(t, index, 64)
+}
+
+func TestEncryptedString(t *testing.T) {
+	db := databasetest.New(t, secret{})
+	database.SetKeyring(newKeyring(t, 1))
+
+	require.NoError(t, db.Create(&secret{ID: 1, Value: "first"}).Error)
+	require.NoError(t, db.Create(&secret{ID: 2, Value: "second"}).Error)
+	require.NoError(t, db.Create(&secret{ID: 3}).Error)
+
+	var stored string
+	require.NoError(t, db.Table("secrets").Select("value").Where("id = ?", 1).Scan(&stored).Error)
+	assert.True(t, strings.HasPrefix(stored, "v1:"))
+
+	var found secret
+	require.NoError(t, db.First(&found, 1).Error)
+	assert.Equal(t, database.EncryptedString("first"), found.Value)
+
+	database.SetKeyring(newKeyring(t, 2))
+	rotated, err := database.RotateEncryption(context.Background(), db, secret{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, rotated)
+
+	rotated, err = database.RotateEncryption(context.Background(), db, secret{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, rotated, "values already use the current key")
+
+	require.NoError(t, db.Table("secrets").Select("value").Where("id = ?", 2).Scan(&stored).Error)
+	assert.True(t, strings.HasPrefix(stored, "v2:"))
+
+	var all []secret
+	require.NoError(t, db.Order("id").Find(&all).Error)
+	require.Len(t, all, 3)
+	assert.Equal(t, database.EncryptedString("second"), all[1].Value)
+	assert.Equal(t, database.EncryptedString(""), all[2].Value)
+}
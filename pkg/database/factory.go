@@ -1,17 +1,63 @@
 package database
 
 import (
+	"fmt"
 	"github.com/imdario/mergo"
+	"gorm.io/gorm"
 	"reflect"
+	"sync/atomic"
 )
 
 // Generator a generator function generates a single record.
 type Generator func() interface{}
 
+// StateFunc a function modifying a generated record to put it in a named state.
+// The record is the value returned by the factory generator.
+type StateFunc func(record interface{})
+
 // Factory an object used to generate records or seed the database.
 type Factory struct {
 	generator Generator
 	override  interface{}
+	states    map[string]StateFunc
+	active    []string
+	relations []relation
+}
+
+// relation associated records generated along with each record.
+type relation struct {
+	factory *Factory
+	field   string
+	count   int
+	many    bool
+}
+
+// Sequence a counter used to generate unique values, such as usernames.
+// A Sequence is safe for concurrent use.
+type Sequence struct {
+	current int64
+}
+
+// NewSequence create a new Sequence, starting at 1.
+func NewSequence() *Sequence {
+	return &Sequence{}
+}
+
+// Next return the next value of the sequence.
+func (s *Sequence) Next() int {
+	return int(atomic.AddInt64(&s.current, 1))
+}
+
+// Format return the next value of the sequence formatted with the given format.
+//
+//  sequence.Format("user%d") // user1, user2, ...
+func (s *Sequence) Format(format string) string {
+	return fmt.Sprintf(format, s.Next())
+}
+
+// Reset restart the sequence at 1.
+func (s *Sequence) Reset() {
+	atomic.StoreInt64(&s.current, 0)
 }
 
 // NewFactory create a new Factory.
@@ -20,6 +66,7 @@ func NewFactory(generator Generator) *Factory {
 	return &Factory{
 		generator: generator,
 		override:  nil,
+		states:    map[string]StateFunc{},
 	}
 }
 
@@ -33,14 +80,65 @@ func (f *Factory) Override(override interface{}) *Factory {
 	return f
 }
 
+// DefineState define a named state that can be applied to generated records with State.
+// Returns the same instance of `Factory` so this method can be chained.
+//
+//  factory.DefineState("suspended", func(record interface{}) {
+//      record.(*User).SuspendedAt = null.TimeFrom(time.Now())
+//  })
+func (f *Factory) DefineState(name string, state StateFunc) *Factory {
+	f.states[name] = state
+	return f
+}
+
+// State return a copy of the factory applying the given states, in order,
+// to the generated records. Overrides are applied after the states.
+//
+//  factory.State("admin", "suspended").Generate(2)
+func (f *Factory) State(names ...string) *Factory {
+	c := f.clone()
+	c.active = append(c.active, names...)
+	return c
+}
+
+// Has return a copy of the factory generating count associated records with the
+// given factory for each record, assigned to the has many association field.
+// The associated records are created along with their owner, in the same transaction.
+//
+//  userFactory.Has(postFactory, 3, "Posts").Save(2)
+func (f *Factory) Has(related *Factory, count int, field string) *Factory {
+	c := f.clone()
+	c.relations = append(c.relations, relation{factory: related, field: field, count: count, many: true})
+	return c
+}
+
+// For return a copy of the factory generating an associated record with the given
+// factory for each record, assigned to the belongs to or has one association field.
+// The associated record is created along with the record, in the same transaction.
+//
+//  postFactory.For(userFactory, "User").Save(2)
+func (f *Factory) For(related *Factory, field string) *Factory {
+	c := f.clone()
+	c.relations = append(c.relations, relation{factory: related, field: field, count: 1})
+	return c
+}
+
+func (f *Factory) clone() *Factory {
+	c := *f
+	c.active = append([]string(nil), f.active...)
+	c.relations = append([]relation(nil), f.relations...)
+	return &c
+}
+
 // Generate a number of records using the given factory.
 // Returns a slice of the actual type of the generated records,
 // meaning you can type-assert safely.
 //
-//  factory.Generate(5).([]*User)
-func (f *Factory) Generate(count int) interface{} {
+//  records, err := factory.Generate(5)
+//  users := records.([]*User)
+func (f *Factory) Generate(count int) (interface{}, error) {
 	if count <= 0 {
-		return []interface{}{}
+		return []interface{}{}, nil
 	}
 	var t reflect.Type
 	var slice reflect.Value
@@ -50,14 +148,84 @@ func (f *Factory) Generate(count int) interface{} {
 			t = reflect.TypeOf(record)
 			slice = reflect.MakeSlice(reflect.SliceOf(t), 0, count)
 		}
+		for _, name := range f.active {
+			state, ok := f.states[name]
+			if !ok {
+				return nil, fmt.Errorf("factory state %q is not defined", name)
+			}
+			state(record)
+		}
 		if f.override != nil {
 			if err := mergo.Merge(record, f.override, mergo.WithOverride); err != nil {
-				panic(err)
+				return nil, err
+			}
+		}
+		for _, rel := range f.relations {
+			if err := rel.assign(record); err != nil {
+				return nil, err
 			}
 		}
 		slice = reflect.Append(slice, reflect.ValueOf(record))
 	}
-	return slice.Interface()
+	return slice.Interface(), nil
+}
+
+// assign generate the related records and set them on the association field of the given record.
+func (r relation) assign(record interface{}) error {
+	target := reflect.Indirect(reflect.ValueOf(record))
+	if target.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot set association %s on %T", r.field, record)
+	}
+	field := target.FieldByName(r.field)
+	if !field.IsValid() || !field.CanSet() {
+		return fmt.Errorf("%T has no association field %s", record, r.field)
+	}
+
+	generated, err := r.factory.Generate(r.count)
+	if err != nil {
+		return err
+	}
+	related := reflect.ValueOf(generated)
+
+	if !r.many {
+		if related.Len() == 0 {
+			return nil
+		}
+		value, err := assignable(related.Index(0), field.Type())
+		if err != nil {
+			return fmt.Errorf("association %s: %w", r.field, err)
+		}
+		field.Set(value)
+		return nil
+	}
+
+	if field.Kind() != reflect.Slice {
+		return fmt.Errorf("association %s of %T is not a slice", r.field, record)
+	}
+	values := reflect.MakeSlice(field.Type(), 0, related.Len())
+	for i := 0; i < related.Len(); i++ {
+		value, err := assignable(related.Index(i), field.Type().Elem())
+		if err != nil {
+			return fmt.Errorf("association %s: %w", r.field, err)
+		}
+		values = reflect.Append(values, value)
+	}
+	field.Set(values)
+	return nil
+}
+
+// assignable return the given value, dereferenced if needed, so it can be assigned to type t.
+func assignable(value reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if value.Type().AssignableTo(t) {
+		return value, nil
+	}
+	if value.Kind() == reflect.Ptr && value.Elem().Type().AssignableTo(t) {
+		return value.Elem(), nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot assign %s to %s", value.Type(), t)
 }
 
 // Save generate a number of records using the given factory,
@@ -65,13 +233,79 @@ func (f *Factory) Generate(count int) interface{} {
 // The returned slice is a slice of the actual type of the generated records,
 // meaning you can type-assert safely.
 //
-//  factory.Save(5).([]*User)
-func (f *Factory) Save(count int) interface{} {
-	db := GetConnection()
-	records := f.Generate(count)
+//  records, err := factory.Save(5)
+//  users := records.([]*User)
+func (f *Factory) Save(count int) (interface{}, error) {
+	db, err := GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	return f.SaveWith(db, count)
+}
+
+// SaveWith same as Save but inserts the records using the given
+// connection or transaction. Records and their associations are inserted
+// in a single transaction.
+func (f *Factory) SaveWith(db *gorm.DB, count int) (interface{}, error) {
+	records, err := f.Generate(count)
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		return records, nil
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(records).Error
+	}); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Make generate a number of records using the given factory without
+// inserting them. T is the type returned by the factory generator.
+//
+//  users, err := database.Make[*User](factory, 5)
+func Make[T any](f *Factory, count int) ([]T, error) {
+	if count <= 0 {
+		return []T{}, nil
+	}
+	records, err := f.Generate(count)
+	if err != nil {
+		return nil, err
+	}
+	return typed[T](records)
+}
+
+// Create generate a number of records using the given factory and insert them,
+// with their associations, in a single transaction on the default connection.
+//
+//  users, err := database.Create[*User](factory, 5)
+func Create[T any](f *Factory, count int) ([]T, error) {
+	db, err := GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	return CreateWith[T](db, f, count)
+}
+
+// CreateWith same as Create but uses the given connection or transaction.
+func CreateWith[T any](db *gorm.DB, f *Factory, count int) ([]T, error) {
+	if count <= 0 {
+		return []T{}, nil
+	}
+	records, err := f.SaveWith(db, count)
+	if err != nil {
+		return nil, err
+	}
+	return typed[T](records)
+}
 
-	if err := db.Create(records).Error; err != nil {
-		panic(err)
+func typed[T any](records interface{}) ([]T, error) {
+	result, ok := records.([]T)
+	if !ok {
+		return nil, fmt.Errorf("factory generates %T, not []%s", records, reflect.TypeOf((*T)(nil)).Elem())
 	}
-	return records
+	return result, nil
 }
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type factoryAuthor struct {
+	Name  string
+	Admin bool
+	Books []factoryBook
+}
+
+type factoryBook struct {
+	Title  string
+	Author *factoryAuthor
+}
+
+func TestFactory(t *testing.T) {
+	fake := NewFaker(42)
+	sequence := NewSequence()
+	authors := NewFactory(func() interface{} {
+		return &factoryAuthor{Name: fake.Name()}
+	}).DefineState("admin", func(record interface{}) {
+		record.(*factoryAuthor).Admin = true
+	})
+	books := NewFactory(func() interface{} {
+		return &factoryBook{Title: sequence.Format("book %d")}
+	})
+
+	t.Run("make", func(t *testing.T) {
+		records, err := Make[*factoryAuthor](authors, 2)
+		require.NoError(t, err)
+		assert.Len(t, records, 2)
+		assert.NotEmpty(t, records[0].Name)
+		assert.False(t, records[0].Admin)
+
+		_, err = Make[factoryAuthor](authors, 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("state", func(t *testing.T) {
+		records, err := Make[*factoryAuthor](authors.State("admin"), 1)
+		require.NoError(t, err)
+		assert.True(t, records[0].Admin)
+
+		_, err = Make[*factoryAuthor](authors.State("unknown"), 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("override", func(t *testing.T) {
+		records, err := Make[*factoryAuthor](authors.State("admin").Override(&factoryAuthor{Name: "Jane"}), 1)
+		require.NoError(t, err)
+		assert.Equal(t, "Jane", records[0].Name)
+		assert.True(t, records[0].Admin)
+	})
+
+	t.Run("has", func(t *testing.T) {
+		sequence.Reset()
+		records, err := Make[*factoryAuthor](authors.Has(books, 2, "Books"), 1)
+		require.NoError(t, err)
+		assert.Equal(t, []factoryBook{{Title: "book 1"}, {Title: "book 2"}}, records[0].Books)
+	})
+
+	t.Run("for", func(t *testing.T) {
+		records, err := Make[*factoryBook](books.For(authors.State("admin"), "Author"), 1)
+		require.NoError(t, err)
+		require.NotNil(t, records[0].Author)
+		assert.True(t, records[0].Author.Admin)
+	})
+}
+
+func TestFakerIsDeterministic(t *testing.T) {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, b := NewFaker(7), NewFaker(7)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, a.Email(), b.Email())
+		date := a.Date(from, to)
+		assert.Equal(t, date, b.Date(from, to))
+		assert.False(t, date.Before(from) || date.After(to))
+	}
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	fakeFirstNames = []string{
+		"Adi", "Agus", "Alice", "Amelia", "Andi", "Budi", "Charlie", "Citra", "Dewi", "Diana",
+		"Eka", "Emma", "Fajar", "Grace", "Hana", "Henry", "Indah", "Isaac", "Joko", "Julia",
+		"Kevin", "Lina", "Lucas", "Maya", "Noah", "Olivia", "Putri", "Rina", "Sari", "Yusuf",
+	}
+	fakeLastNames = []string{
+		"Anderson", "Brown", "Gunawan", "Halim", "Harris", "Hidayat", "Johnson", "Kusuma", "Lestari", "Miller",
+		"Nugroho", "Pratama", "Putra", "Santoso", "Saputra", "Setiawan", "Smith", "Susanto", "Taylor", "Wijaya",
+	}
+	fakeDomains = []string{"example.com", "example.net", "example.org"}
+)
+
+// Faker a fake data provider for factories.
+// Given the same seed, a Faker generates the same sequence of values,
+// which keeps generated fixtures reproducible. A Faker is safe for concurrent use.
+type Faker struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewFaker create a new Faker using the given seed.
+func NewFaker(seed int64) *Faker {
+	return &Faker{rand: rand.New(rand.NewSource(seed))}
+}
+
+// IntBetween return a random integer in [min, max].
+func (f *Faker) IntBetween(min, max int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return min + f.rand.Intn(max-min+1)
+}
+
+// Pick return one of the given values.
+func (f *Faker) Pick(values ...string) string {
+	return values[f.IntBetween(0, len(values)-1)]
+}
+
+// FirstName return a random first name.
+func (f *Faker) FirstName() string {
+	return f.Pick(fakeFirstNames...)
+}
+
+// LastName return a random last name.
+func (f *Faker) LastName() string {
+	return f.Pick(fakeLastNames...)
+}
+
+// Name return a random full name.
+func (f *Faker) Name() string {
+	return f.FirstName() + " " + f.LastName()
+}
+
+// Username return a random lower case username such as "alice.smith42".
+// Combine it with a Sequence when the username must be unique.
+func (f *Faker) Username() string {
+	return fmt.Sprintf("%s.%s%d", strings.ToLower(f.FirstName()), strings.ToLower(f.LastName()), f.IntBetween(1, 99))
+}
+
+// Email return a random email address on a reserved example domain.
+func (f *Faker) Email() string {
+	return f.Username() + "@" + f.Pick(fakeDomains...)
+}
+
+// Date return a random time between from and to, truncated to the second.
+func (f *Faker) Date(from, to time.Time) time.Time {
+	span := to.Unix() - from.Unix()
+	if span <= 0 {
+		return from
+	}
+	f.mu.Lock()
+	offset := f.rand.Int63n(span + 1)
+	f.mu.Unlock()
+	return time.Unix(from.Unix()+offset, 0).In(from.Location())
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Logger a GORM logger writing JSON entries with the gommon logger.
+//
+// Queries are logged with their placeholders, parameters are never written to
+// the logs. Queries run with a QueryStats context are counted, and a query run
+// NPlusOneThreshold times by the same request is reported as a possible N+1.
+//
+// The logger captures the SQL through callbacks and must also be registered
+// as a plugin:
+//
+//  queryLogger := database.NewLogger(name)
+//  db, err := gorm.Open(dialector, &gorm.Config{Logger: queryLogger})
+//  err = db.Use(queryLogger)
+type Logger struct {
+	// Connection name of the connection, added to the entries.
+	Connection string
+	// LogLevel the entries written: errors, slow queries and N+1 warnings, or every query.
+	LogLevel logger.LogLevel
+	// SlowThreshold duration above which a query is logged as slow, 0 disabling it.
+	SlowThreshold time.Duration
+	// NPlusOneThreshold number of runs of a query by a request reported as N+1, 0 disabling it.
+	NPlusOneThreshold int
+}
+
+var logLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
+// NewLogger create the logger of the given connection, configured by "database.log.level"
+// (silent, error, warn or info, info by default with "app.debug"),
+// "database.log.slowThreshold" milliseconds and "database.log.nPlusOneThreshold".
+func NewLogger(name string) *Logger {
+	level := logger.Warn
+	if viper.GetBool("app.debug") {
+		level = logger.Info
+	}
+	if configured := viper.GetString(settingKey(name, "log.level")); configured != "" {
+		if l, ok := logLevels[strings.ToLower(configured)]; ok {
+			level = l
+		} else {
+			log.Warnf("database connection %s: unknown log level %q", name, configured)
+		}
+	}
+	return &Logger{
+		Connection:        name,
+		LogLevel:          level,
+		SlowThreshold:     time.Duration(viper.GetInt(settingKey(name, "log.slowThreshold"))) * time.Millisecond,
+		NPlusOneThreshold: viper.GetInt(settingKey(name, "log.nPlusOneThreshold")),
+	}
+}
+
+// LogMode implements logger.Interface.
+func (l *Logger) LogMode(level logger.LogLevel) logger.Interface {
+	newLogger := *l
+	newLogger.LogLevel = level
+	return &newLogger
+}
+
+// Info implements logger.Interface.
+func (l *Logger) Info(_ context.Context, msg string, data ...interface{}) {
+	if l.LogLevel >= logger.Info {
+		log.Infoj(l.entry(fmt.Sprintf(msg, data...)))
+	}
+}
+
+// Warn implements logger.Interface.
+func (l *Logger) Warn(_ context.Context, msg string, data ...interface{}) {
+	if l.LogLevel >= logger.Warn {
+		log.Warnj(l.entry(fmt.Sprintf(msg, data...)))
+	}
+}
+
+// Error implements logger.Interface.
+func (l *Logger) Error(_ context.Context, msg string, data ...interface{}) {
+	if l.LogLevel >= logger.Error {
+		log.Errorj(l.entry(fmt.Sprintf(msg, data...)))
+	}
+}
+
+// Trace implements logger.Interface, logging the query and recording it in the QueryStats of ctx.
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	sql, _ := ctx.Value(capturedSQLKey{}).(string)
+	fingerprint := Fingerprint(sql)
+
+	if stats := QueryStatsFromContext(ctx); stats != nil {
+		runs := stats.record(fingerprint, elapsed)
+		if sql != "" && l.NPlusOneThreshold > 0 && runs == l.NPlusOneThreshold && l.LogLevel >= logger.Warn {
+			entry := l.entry("possible N+1 query")
+			entry["sql"] = fingerprint
+			entry["runs"] = runs
+			entry["request"] = stats.Label
+			log.Warnj(entry)
+		}
+	}
+
+	if l.LogLevel <= logger.Silent {
+		return
+	}
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := l.SlowThreshold > 0 && elapsed > l.SlowThreshold
+	if !(failed && l.LogLevel >= logger.Error) && !(slow && l.LogLevel >= logger.Warn) && l.LogLevel < logger.Info {
+		return
+	}
+
+	_, rows := fc()
+	entry := l.entry("query")
+	entry["sql"] = sql
+	entry["duration_ms"] = float64(elapsed.Microseconds()) / 1000
+	entry["rows"] = rows
+	switch {
+	case failed && l.LogLevel >= logger.Error:
+		entry["message"] = "query failed"
+		entry["error"] = err.Error()
+		log.Errorj(entry)
+	case slow && l.LogLevel >= logger.Warn:
+		entry["message"] = "slow query"
+		entry["threshold_ms"] = l.SlowThreshold.Milliseconds()
+		log.Warnj(entry)
+	default:
+		log.Infoj(entry)
+	}
+}
+
+func (l *Logger) entry(message string) log.JSON {
+	return log.JSON{"message": message, "component": "database", "connection": l.Connection}
+}
+
+// capturedSQLKey context key of the SQL of the statement traced by the logger.
+type capturedSQLKey struct{}
+
+// Name implements gorm.Plugin.
+func (l *Logger) Name() string {
+	return "database:logger"
+}
+
+// Initialize implements gorm.Plugin, registering the callback capturing the SQL of the
+// statements before GORM traces them with their parameters.
+func (l *Logger) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().After("*").Register("database:capture_sql", captureSQL),
+		callbacks.Query().After("*").Register("database:capture_sql", captureSQL),
+		callbacks.Update().After("*").Register("database:capture_sql", captureSQL),
+		callbacks.Delete().After("*").Register("database:capture_sql", captureSQL),
+		callbacks.Row().After("*").Register("database:capture_sql", captureSQL),
+		callbacks.Raw().After("*").Register("database:capture_sql", captureSQL),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func captureSQL(db *gorm.DB) {
+	if db.Statement.Context == nil {
+		db.Statement.Context = context.Background()
+	}
+	db.Statement.Context = context.WithValue(db.Statement.Context, capturedSQLKey{}, db.Statement.SQL.String())
+}
+
+var (
+	fingerprintLiterals     = regexp.MustCompile(`'(?:[^']|'')*'|\$\d+|@p\d+|\b\d+\b`)
+	fingerprintPlaceholders = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+	fingerprintSpaces       = regexp.MustCompile(`\s+`)
+)
+
+// Fingerprint return sql with its literals and placeholders replaced by "?" and lists of
+// placeholders collapsed, identifying the queries differing only by their parameters.
+func Fingerprint(sql string) string {
+	sql = fingerprintSpaces.ReplaceAllString(strings.TrimSpace(sql), " ")
+	sql = fingerprintLiterals.ReplaceAllString(sql, "?")
+	return fingerprintPlaceholders.ReplaceAllString(sql, "?")
+}
+
+// QueryStats counts the queries run with a context, such as those of a request.
+type QueryStats struct {
+	// Label identifies the context in the N+1 warnings, such as the method and path of a request.
+	Label string
+
+	mu           sync.Mutex
+	count        int
+	duration     time.Duration
+	fingerprints map[string]int
+}
+
+type queryStatsContextKey struct{}
+
+// WithQueryStats return a context recording its queries in the returned stats.
+func WithQueryStats(ctx context.Context, label string) (context.Context, *QueryStats) {
+	stats := &QueryStats{Label: label, fingerprints: map[string]int{}}
+	return context.WithValue(ctx, queryStatsContextKey{}, stats), stats
+}
+
+// QueryStatsFromContext return the stats of WithQueryStats, nil without.
+func QueryStatsFromContext(ctx context.Context) *QueryStats {
+	stats, _ := ctx.Value(queryStatsContextKey{}).(*QueryStats)
+	return stats
+}
+
+// Count return the number of queries run.
+func (s *QueryStats) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Duration return the total time spent running the queries.
+func (s *QueryStats) Duration() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.duration
+}
+
+// record a query, returning the number of runs of its fingerprint.
+func (s *QueryStats) record(fingerprint string, elapsed time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	s.duration += elapsed
+	if fingerprint == "" {
+		return 0
+	}
+	s.fingerprints[fingerprint]++
+	return s.fingerprints[fingerprint]
+}
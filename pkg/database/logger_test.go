@@ -0,0 +1,92 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type account struct {
+	ID       uint
+	Username string
+	Password string
+}
+
+func openWithLogger(t *testing.T, l *database.Logger) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: l})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(l))
+	require.NoError(t, db.AutoMigrate(&account{}))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	output := &bytes.Buffer{}
+	log.SetOutput(output)
+	t.Cleanup(func() { log.SetOutput(os.Stdout) })
+	return output
+}
+
+func TestLoggerMasksParameters(t *testing.T) {
+	db := openWithLogger(t, &database.Logger{Connection: "default", LogLevel: logger.Info})
+	output := captureLog(t)
+
+	require.NoError(t, db.Create(&account{Username: "alice", Password: "s3cr3t-p4ss"}).Error)
+	require.NoError(t, db.Where("password = ?", "s3cr3t-p4ss").First(&account{}).Error)
+
+	assert.Contains(t, output.String(), `"sql":"SELECT * FROM `+"`accounts`"+` WHERE password = ?`)
+	assert.NotContains(t, output.String(), "s3cr3t-p4ss")
+	assert.NotContains(t, output.String(), "alice")
+}
+
+func TestLoggerSlowQueries(t *testing.T) {
+	db := openWithLogger(t, &database.Logger{LogLevel: logger.Warn, SlowThreshold: time.Nanosecond})
+	output := captureLog(t)
+
+	require.NoError(t, db.Find(&[]account{}).Error)
+	assert.Contains(t, output.String(), `"message":"slow query"`)
+
+	output.Reset()
+	require.Error(t, db.Exec("SELECT * FROM missing").Error)
+	assert.Contains(t, output.String(), `"message":"query failed"`)
+}
+
+func TestQueryStats(t *testing.T) {
+	db := openWithLogger(t, &database.Logger{LogLevel: logger.Warn, NPlusOneThreshold: 3})
+	output := captureLog(t)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, db.Create(&account{Username: "user"}).Error)
+	}
+
+	ctx, stats := database.WithQueryStats(context.Background(), "GET /accounts")
+	var accounts []account
+	require.NoError(t, db.WithContext(ctx).Find(&accounts).Error)
+	for _, a := range accounts {
+		require.NoError(t, db.WithContext(ctx).First(&account{}, a.ID).Error)
+	}
+
+	assert.Equal(t, 6, stats.Count())
+	assert.Greater(t, int64(stats.Duration()), int64(0))
+	assert.Equal(t, 1, bytes.Count(output.Bytes(), []byte("possible N+1 query")), "reported once")
+	assert.Contains(t, output.String(), `"request":"GET /accounts"`)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, "SELECT * FROM users WHERE id IN (?) AND name = ? LIMIT ?",
+		database.Fingerprint("SELECT *  FROM users\n WHERE id IN (?,?, ?) AND name = 'it''s' LIMIT 10"))
+	assert.Equal(t, database.Fingerprint(`SELECT * FROM "users" WHERE "id" = $1`),
+		database.Fingerprint(`SELECT * FROM "users" WHERE "id" = $2`))
+}
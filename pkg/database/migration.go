@@ -0,0 +1,665 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// MigrationFunc applies or reverts a Go migration inside the given transaction.
+type MigrationFunc func(tx *gorm.DB) error
+
+// Migration a single versioned schema change.
+//
+// A migration is written either in Go, using Up and Down, or in SQL, using
+// UpSQL and DownSQL. SQL migrations are keyed by dialect name, the "" key being
+// used by every dialect without a specific variant.
+//
+// Connection is the name of the connection the migration applies to,
+// the default connection when empty.
+type Migration struct {
+	Version    int64
+	Name       string
+	Connection string
+
+	Up   MigrationFunc
+	Down MigrationFunc
+
+	UpSQL   map[string]string
+	DownSQL map[string]string
+}
+
+// MigrationStatus state of a migration in the current database.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is true when the applied checksum differs from the current source.
+	Modified bool
+	// Missing is true when the migration is applied but its source is gone.
+	Missing bool
+}
+
+// SchemaMigration a row of the schema_migrations table.
+type SchemaMigration struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;type:varchar(255)"`
+	Checksum  string    `gorm:"column:checksum;type:varchar(64)"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationLocker acquires an exclusive, session-level lock named name on the given connection,
+// waiting at most timeout. The returned function releases the lock.
+type MigrationLocker func(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) (release func() error, err error)
+
+// MigrationLockName name of the lock held while migrating.
+const MigrationLockName = "go_api_schema_migrations"
+
+var (
+	// ErrMigrationLocked returned when another instance holds the migration lock.
+	ErrMigrationLocked = errors.New("migrations are locked by another instance")
+	// ErrNoMigrationsTable returned by Status when no migration was ever applied.
+	ErrNoMigrationsTable = errors.New("no migrations table")
+
+	registeredMigrations []Migration
+	migrationLockers     = map[string]MigrationLocker{}
+
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)(?:\.(\w+))?\.sql$`)
+
+	// nonTransactionalDDL dialects whose DDL statements commit implicitly, so that a
+	// failed migration keeps its statements run before the failure.
+	nonTransactionalDDL = map[string]bool{"mysql": true}
+)
+
+// RegisterMigration register a Go migration.
+// Usually called from the init function of the package holding the migration.
+func RegisterMigration(migration Migration) {
+	registeredMigrations = append(registeredMigrations, migration)
+}
+
+// ClearRegisteredMigrations unregister all Go migrations.
+func ClearRegisteredMigrations() {
+	registeredMigrations = []Migration{}
+}
+
+// RegisterMigrationLocker register the function used to lock migrations for the given dialect.
+func RegisterMigrationLocker(name string, locker MigrationLocker) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := migrationLockers[name]; ok {
+		panic(fmt.Sprintf("Migration locker for dialect %q already exists", name))
+	}
+	migrationLockers[name] = locker
+}
+
+// Migrator applies and reverts versioned migrations on a database.
+type Migrator struct {
+	db         *gorm.DB
+	dialect    string
+	connection string
+	migrations []Migration
+}
+
+// NewMigrator create a new Migrator for the given connection and dialect name.
+// Migrations are the Go migrations registered for the default connection
+// and the SQL files found in dir.
+func NewMigrator(db *gorm.DB, dialect, dir string) (*Migrator, error) {
+	return newMigrator(db, dialect, dir, DefaultConnection)
+}
+
+func newMigrator(db *gorm.DB, dialect, dir, connection string) (*Migrator, error) {
+	migrations, err := LoadSQLMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, migration := range registeredMigrations {
+		if migration.Connection == connection || (migration.Connection == "" && connection == DefaultConnection) {
+			migrations = append(migrations, migration)
+		}
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d (%s, %s)",
+				migrations[i].Version, migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+
+	return &Migrator{db: db, dialect: dialect, connection: connection, migrations: migrations}, nil
+}
+
+// DefaultMigrator create a Migrator for the default connection using the
+// dialect from "database.connection" and the directory from "database.migrations.dir".
+func DefaultMigrator() (*Migrator, error) {
+	return NamedMigrator(DefaultConnection)
+}
+
+// NamedMigrator create a Migrator for the given connection, see MigrationsDir.
+func NamedMigrator(name string) (*Migrator, error) {
+	db, err := GetNamedConnection(name)
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(db, DialectName(name), MigrationsDir(name), name)
+}
+
+// MigrationsDir return the directory holding the SQL migrations of the given connection:
+// its "migrations.dir" setting or, for a named connection without one,
+// the sub directory of "database.migrations.dir" named after the connection.
+func MigrationsDir(name string) string {
+	key := settingKey(name, "migrations.dir")
+	if name == DefaultConnection || viper.IsSet(key) {
+		return viper.GetString(key)
+	}
+	return filepath.Join(viper.GetString("database.migrations.dir"), name)
+}
+
+// LoadSQLMigrations read the SQL migrations from the given directory.
+//
+// Files are named "{version}_{name}.{up|down}[.{dialect}].sql", for example
+// "20211019120000_create_users.up.sql" or "20211019120000_create_users.up.mssql.sql".
+// A missing directory holds no migrations.
+func LoadSQLMigrations(dir string) ([]Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	var versions []int64
+	for _, file := range files {
+		matches := migrationFilePattern.FindStringSubmatch(file.Name())
+		if file.IsDir() || matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", file.Name(), err)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2], UpSQL: map[string]string{}, DownSQL: map[string]string{}}
+			byVersion[version] = migration
+			versions = append(versions, version)
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has several names (%s, %s)", version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.UpSQL[matches[4]] = string(content)
+		} else {
+			migration.DownSQL[matches[4]] = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		migrations = append(migrations, *byVersion[version])
+	}
+	return migrations, nil
+}
+
+// CreateMigration create empty up and down SQL files for a new migration in
+// the given directory and return their paths.
+func CreateMigration(dir, name string, now time.Time) ([]string, error) {
+	name = strings.Trim(regexp.MustCompile(`\W+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	version := now.UTC().Format("20060102150405")
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %s migration %s_%s\n", direction, version, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Migrations return the known migrations sorted by version.
+func (m *Migrator) Migrations() []Migration {
+	return append(make([]Migration, 0, len(m.migrations)), m.migrations...)
+}
+
+// Up apply pending migrations in order and return the applied ones.
+// At most steps migrations are applied, all of them if steps <= 0.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(db *gorm.DB, applied map[int64]SchemaMigration) error {
+		if err := m.verify(db, applied); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if steps > 0 && len(done) >= steps {
+				break
+			}
+			if err := m.apply(db, migration); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down revert the last applied migrations and return the reverted ones.
+// steps defaults to 1 when <= 0.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	var done []Migration
+	err := m.withLock(ctx, func(db *gorm.DB, applied map[int64]SchemaMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.revert(db, migration); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status return the state of every known or applied migration, sorted by version.
+// It does not write to the database: without the migrations table, it returns every
+// migration as pending along with ErrNoMigrationsTable.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	db := m.session(ctx)
+	var missingTable error
+	applied := map[int64]SchemaMigration{}
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		var rows []SchemaMigration
+		if err := db.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			applied[row.Version] = row
+		}
+	} else {
+		missingTable = ErrNoMigrationsTable
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.AppliedAt
+			status.Modified = !m.checksumMatches(row, migration)
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version: row.Version, Name: row.Name, Applied: true, AppliedAt: row.AppliedAt, Missing: true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, missingTable
+}
+
+// session return the database bound to ctx, in the schema of the tenant of ctx if any.
+func (m *Migrator) session(ctx context.Context) *gorm.DB {
+	if db, ok := tenantDB(ctx); ok && m.connection == DefaultConnection {
+		return db
+	}
+	return m.db.WithContext(ctx)
+}
+
+// withLock call fn with the migration lock held, on the connection holding the lock:
+// that of the tenant of ctx if any, a connection of the pool otherwise.
+func (m *Migrator) withLock(ctx context.Context, fn func(db *gorm.DB, applied map[int64]SchemaMigration) error) error {
+	locker, ok := migrationLockers[m.dialect]
+	if !ok {
+		return fmt.Errorf("no migration locker registered for dialect %q", m.dialect)
+	}
+
+	db := m.session(ctx)
+	conn, ok := db.Statement.ConnPool.(*sql.Conn)
+	if !ok {
+		sqlDB, err := m.db.DB()
+		if err != nil {
+			return err
+		}
+		if conn, err = sqlDB.Conn(ctx); err != nil {
+			return err
+		}
+		defer conn.Close()
+		// the migrations run on the locked connection, the pool may have no other
+		db = m.db.Session(&gorm.Session{Context: ctx})
+		db.Statement.ConnPool = conn
+	}
+
+	timeout := time.Duration(viper.GetInt(settingKey(m.connection, "migrations.lockTimeout"))) * time.Second
+	release, err := locker(ctx, conn, MigrationLockName, timeout)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	applied, err := m.applied(db)
+	if err != nil {
+		return err
+	}
+	return fn(db, applied)
+}
+
+func (m *Migrator) applied(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// verify check that the applied migrations were not modified, updating the checksums
+// computed from their up script only to the current checksum.
+func (m *Migrator) verify(db *gorm.DB, applied map[int64]SchemaMigration) error {
+	for _, migration := range m.migrations {
+		row, ok := applied[migration.Version]
+		if !ok || row.Checksum == m.checksum(migration) {
+			continue
+		}
+		if !m.checksumMatches(row, migration) {
+			return fmt.Errorf("migration %d_%s was modified after being applied", migration.Version, migration.Name)
+		}
+		if err := db.Model(&SchemaMigration{}).Where("version = ?", migration.Version).
+			Update("checksum", m.checksum(migration)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(db *gorm.DB, migration Migration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := m.run(tx, migration.Up, migration.UpSQL); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  m.checksum(migration),
+			AppliedAt: time.Now(),
+		}).Error
+	})
+}
+
+func (m *Migrator) revert(db *gorm.DB, migration Migration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := m.run(tx, migration.Down, migration.DownSQL); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+	})
+}
+
+func (m *Migrator) run(tx *gorm.DB, fn MigrationFunc, scripts map[string]string) error {
+	if fn != nil {
+		return fn(tx)
+	}
+	script, ok := m.script(scripts)
+	if !ok {
+		return fmt.Errorf("no %s migration available", m.dialect)
+	}
+	for i, statement := range SplitStatements(m.dialect, script) {
+		if err := tx.Exec(statement).Error; err != nil {
+			if i > 0 && nonTransactionalDDL[m.dialect] {
+				return fmt.Errorf("statement %d: %w (the schema changes of the statements before it were committed by %s and must be reverted by hand)", i+1, err, m.dialect)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) script(scripts map[string]string) (string, bool) {
+	if script, ok := scripts[m.dialect]; ok {
+		return script, true
+	}
+	script, ok := scripts[""]
+	return script, ok
+}
+
+// checksum of the migration source for the migrator dialect, its up and down scripts.
+// Go migrations are identified by their name only.
+func (m *Migrator) checksum(migration Migration) string {
+	if migration.Up != nil {
+		return sha256Hex("go:" + migration.Name)
+	}
+	up, _ := m.script(migration.UpSQL)
+	down, _ := m.script(migration.DownSQL)
+	return sha256Hex(up + "\x00" + down)
+}
+
+// checksumMatches tells whether the checksum of an applied migration is the one of
+// its source, or the checksum of its up script only recorded by older versions.
+func (m *Migrator) checksumMatches(row SchemaMigration, migration Migration) bool {
+	if row.Checksum == m.checksum(migration) {
+		return true
+	}
+	up, _ := m.script(migration.UpSQL)
+	return migration.Up == nil && row.Checksum == sha256Hex(up)
+}
+
+func sha256Hex(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// SplitStatements split a SQL script of the given dialect into statements separated
+// by semicolons, ignoring the ones inside quotes, dollar-quoted strings and comments.
+// Backslashes escape quotes in MySQL strings and in Postgres E'...' strings, and block
+// comments nest in Postgres and SQL Server.
+//
+// As with their command line clients, a "DELIMITER $$" line changes the separator
+// of the following MySQL statements, and a SQL Server script with "GO" lines is split
+// into batches at these lines only. In any dialect, the lines between
+// "-- +migrate StatementBegin" and "-- +migrate StatementEnd" are a single statement.
+func SplitStatements(dialect, script string) []string {
+	var statements []string
+	start, code := 0, false
+	delimiter := ";"
+	batches := dialect == "mssql" && hasBatchSeparator(script)
+	end := func(i, next int) {
+		if code {
+			statements = append(statements, strings.TrimSpace(script[start:i]))
+		}
+		start, code = next, false
+	}
+	for i := 0; i < len(script); i++ {
+		if i == 0 || script[i-1] == '\n' {
+			lineEnd := skipTo(script, i, "\n")
+			fields := strings.Fields(script[i:lineEnd])
+			switch {
+			case batches && len(fields) == 1 && strings.EqualFold(fields[0], "GO"):
+				end(i, lineEnd+1)
+				i = lineEnd
+				continue
+			case dialect == "mysql" && len(fields) == 2 && strings.EqualFold(fields[0], "DELIMITER"):
+				end(i, lineEnd+1)
+				delimiter = fields[1]
+				i = lineEnd
+				continue
+			}
+		}
+		switch c := script[i]; {
+		case !batches && strings.HasPrefix(script[i:], delimiter):
+			end(i, i+len(delimiter))
+			i += len(delimiter) - 1
+			continue
+		case strings.HasPrefix(script[i:], "--"):
+			lineEnd := skipTo(script, i+2, "\n")
+			if strings.TrimSpace(script[i+2:lineEnd]) == statementBegin {
+				end(i, i)
+				blockEnd := len(script)
+				if index := strings.Index(script[i:], "-- "+statementEnd); index >= 0 {
+					blockEnd = i + index
+				}
+				if lineEnd < blockEnd {
+					if block := strings.TrimSpace(script[lineEnd+1 : blockEnd]); block != "" {
+						statements = append(statements, block)
+					}
+				}
+				i = skipTo(script, blockEnd, "\n")
+				start = i + 1
+				continue
+			}
+			i = lineEnd
+			continue
+		case strings.HasPrefix(script[i:], "/*"):
+			i = skipComment(script, i, dialect != "mysql")
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			escapes := dialect == "mysql" || (c == '\'' && dialect == "postgres" && i > 0 &&
+				(script[i-1] == 'E' || script[i-1] == 'e') && (i == 1 || !isIdentifierByte(script[i-2])))
+			i = skipQuoted(script, i, escapes)
+		case c == '$' && (i == 0 || !isIdentifierByte(script[i-1])):
+			if tag := dollarTag(script[i:]); tag != "" {
+				i = skipTo(script, i+len(tag), tag)
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		}
+		// statements made of comments only are not sent to the database
+		code = true
+	}
+	end(len(script), len(script))
+	return statements
+}
+
+const (
+	statementBegin = "+migrate StatementBegin"
+	statementEnd   = "+migrate StatementEnd"
+)
+
+// hasBatchSeparator tells whether a SQL Server script has "GO" lines.
+func hasBatchSeparator(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		if strings.EqualFold(strings.TrimSpace(line), "GO") {
+			return true
+		}
+	}
+	return false
+}
+
+// skipTo return the index of the last byte of the next occurrence of end in
+// script, starting at from, or the length of the script if there is none.
+func skipTo(script string, from int, end string) int {
+	if from < len(script) {
+		if index := strings.Index(script[from:], end); index >= 0 {
+			return from + index + len(end) - 1
+		}
+	}
+	return len(script)
+}
+
+// skipQuoted return the index of the quote closing the string opened at from,
+// the quote following a backslash being escaped when escapes is set.
+func skipQuoted(script string, from int, escapes bool) int {
+	quote := script[from]
+	for i := from + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return len(script)
+}
+
+// skipComment return the index of the last byte of the block comment opened at from.
+func skipComment(script string, from int, nested bool) int {
+	depth := 0
+	for i := from; i < len(script)-1; i++ {
+		switch {
+		case script[i] == '/' && script[i+1] == '*' && (nested || depth == 0):
+			depth++
+			i++
+		case script[i] == '*' && script[i+1] == '/':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+			i++
+		}
+	}
+	return len(script)
+}
+
+// dollarTag return the $tag$ opening a dollar-quoted string at the start of script.
+func dollarTag(script string) string {
+	for i := 1; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '$':
+			return script[:i+1]
+		case !isIdentifierByte(c) || (i == 1 && c >= '0' && c <= '9'):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
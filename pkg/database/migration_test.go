@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- create the table
+CREATE TABLE notes (body varchar(20) DEFAULT 'a;b');
+INSERT INTO notes VALUES ('it''s; fine');
+CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN NEW.body := ';'; RETURN NEW; END; $$ LANGUAGE plpgsql;
+-- trailing comment;
+`
+	statements := SplitStatements("postgres", script)
+
+	require.Len(t, statements, 3)
+	assert.Equal(t, "-- create the table\nCREATE TABLE notes (body varchar(20) DEFAULT 'a;b')", statements[0])
+	assert.Equal(t, "INSERT INTO notes VALUES ('it''s; fine')", statements[1])
+	assert.Contains(t, statements[2], "RETURN NEW; END; $$ LANGUAGE plpgsql")
+}
+
+func TestSplitStatementsDialects(t *testing.T) {
+	postgres := `/* setup; /* nested; */ still a comment; */
+CREATE FUNCTION f() RETURNS text AS $body$ SELECT 'a;b'; $body$ LANGUAGE sql;
+INSERT INTO notes VALUES (E'it\'s; escaped', 'C:\', $1);
+/* only a comment; */`
+	assert.Equal(t, []string{
+		"/* setup; /* nested; */ still a comment; */\nCREATE FUNCTION f() RETURNS text AS $body$ SELECT 'a;b'; $body$ LANGUAGE sql",
+		`INSERT INTO notes VALUES (E'it\'s; escaped', 'C:\', $1)`,
+	}, SplitStatements("postgres", postgres))
+
+	mysql := `INSERT INTO notes VALUES ('it\'s; escaped', "say \"hi;\"");
+/* not /* nested; */ SELECT 1;`
+	assert.Equal(t, []string{
+		`INSERT INTO notes VALUES ('it\'s; escaped', "say \"hi;\"")`,
+		"/* not /* nested; */ SELECT 1",
+	}, SplitStatements("mysql", mysql))
+}
+
+func TestSplitStatementsSeparators(t *testing.T) {
+	mssql := `CREATE TABLE notes (body varchar(20));
+GO
+CREATE PROCEDURE touch AS BEGIN UPDATE notes SET body = 'a'; SELECT 1; END
+go
+`
+	assert.Equal(t, []string{
+		"CREATE TABLE notes (body varchar(20));",
+		"CREATE PROCEDURE touch AS BEGIN UPDATE notes SET body = 'a'; SELECT 1; END",
+	}, SplitStatements("mssql", mssql))
+	assert.Len(t, SplitStatements("mssql", "SELECT 1; SELECT 2;"), 2, "scripts without GO are split at the semicolons")
+
+	mysql := `CREATE TABLE notes (body text);
+DELIMITER $$
+CREATE TRIGGER touch BEFORE INSERT ON notes FOR EACH ROW BEGIN SET NEW.body = ';'; END$$
+DELIMITER ;
+INSERT INTO notes VALUES ('a');`
+	assert.Equal(t, []string{
+		"CREATE TABLE notes (body text)",
+		"CREATE TRIGGER touch BEFORE INSERT ON notes FOR EACH ROW BEGIN SET NEW.body = ';'; END",
+		"INSERT INTO notes VALUES ('a')",
+	}, SplitStatements("mysql", mysql))
+
+	block := `CREATE TABLE notes (body text);
+-- +migrate StatementBegin
+CREATE TRIGGER touch AFTER INSERT ON notes BEGIN
+  UPDATE notes SET body = 'a';
+END;
+-- +migrate StatementEnd
+INSERT INTO notes VALUES ('a');`
+	assert.Equal(t, []string{
+		"CREATE TABLE notes (body text)",
+		"CREATE TRIGGER touch AFTER INSERT ON notes BEGIN\n  UPDATE notes SET body = 'a';\nEND;",
+		"INSERT INTO notes VALUES ('a')",
+	}, SplitStatements("sqlite", block))
+}
+
+func TestMigratorOnSingleConnection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	up := filepath.Join(dir, "1_create_notes.up.sql")
+	down := filepath.Join(dir, "1_create_notes.down.sql")
+	require.NoError(t, ioutil.WriteFile(up, []byte("CREATE TABLE notes (body text); /* done; */"), 0644))
+	require.NoError(t, ioutil.WriteFile(down, []byte("DROP TABLE notes;"), 0644))
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	sqlDB.SetMaxOpenConns(1)
+
+	migrator, err := NewMigrator(db, "sqlite", dir)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	statuses, err := migrator.Status(ctx)
+	assert.ErrorIs(t, err, ErrNoMigrationsTable)
+	require.Len(t, statuses, 1)
+	assert.False(t, statuses[0].Applied)
+	assert.False(t, db.Migrator().HasTable(&SchemaMigration{}), "the status is read-only")
+
+	done, err := migrator.Up(ctx, 0)
+	require.NoError(t, err, "the migrations run on the locked connection")
+	assert.Len(t, done, 1)
+	assert.True(t, db.Migrator().HasTable("notes"))
+
+	// a modified down script changes the checksum
+	require.NoError(t, ioutil.WriteFile(down, []byte("DROP TABLE IF EXISTS notes;"), 0644))
+	migrator, err = NewMigrator(db, "sqlite", dir)
+	require.NoError(t, err)
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Modified)
+
+	// checksums of the up script only are accepted and updated
+	up1 := sha256Hex("CREATE TABLE notes (body text); /* done; */")
+	require.NoError(t, db.Model(&SchemaMigration{}).Where("version = 1").Update("checksum", up1).Error)
+	_, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+	var row SchemaMigration
+	require.NoError(t, db.First(&row, "version = 1").Error)
+	assert.Equal(t, migrator.checksum(migrator.Migrations()[0]), row.Checksum)
+
+	done, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, done, 1)
+	assert.False(t, db.Migrator().HasTable("notes"))
+
+	// without transactional DDL, the error tells the first statements were committed
+	nonTransactionalDDL["sqlite"] = true
+	defer delete(nonTransactionalDDL, "sqlite")
+	require.NoError(t, ioutil.WriteFile(up, []byte("CREATE TABLE notes (body text); INSERT INTO missing VALUES (1);"), 0644))
+	migrator, err = NewMigrator(db, "sqlite", dir)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "statement 2: ")
+	assert.Contains(t, err.Error(), "committed by sqlite")
+}
+
+func TestLoadSQLMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	paths, err := CreateMigration(dir, "Create Notes", time.Date(2021, 10, 19, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "20211019120000_create_notes.up.sql"),
+		filepath.Join(dir, "20211019120000_create_notes.down.sql"),
+	}, paths)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "20211019120000_create_notes.up.mssql.sql"), []byte("SELECT 1"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0644))
+
+	migrations, err := LoadSQLMigrations(dir)
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+	assert.Equal(t, int64(20211019120000), migrations[0].Version)
+	assert.Equal(t, "create_notes", migrations[0].Name)
+	assert.Len(t, migrations[0].UpSQL, 2)
+	assert.Equal(t, "SELECT 1", migrations[0].UpSQL["mssql"])
+	assert.Len(t, migrations[0].DownSQL, 1)
+
+	m := &Migrator{dialect: "mssql"}
+	script, ok := m.script(migrations[0].UpSQL)
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT 1", script)
+}
@@ -30,7 +30,8 @@ func paginateScope(page, pageSize int) func(db *gorm.DB) *gorm.DB {
 // filter results.
 //
 //  articles := []model.Article{}
-//  tx := database.Conn().Where("title LIKE ?", "%"+helper.EscapeLike(search)+"%")
+//  db, err := database.Conn()
+//  tx := db.Where("title LIKE ?", "%"+helper.EscapeLike(search)+"%")
 //  paginator := database.NewPaginator(tx, page, pageSize, &articles)
 //  result := paginator.Find()
 //  if response.HandleDatabaseError(result) {
@@ -46,22 +47,27 @@ func NewPaginator(db *gorm.DB, page, pageSize int, dest interface{}) *Paginator
 	}
 }
 
-func (p *Paginator) updatePageInfo() {
+func (p *Paginator) updatePageInfo() error {
 	count := int64(0)
 	if err := p.db.Model(p.Records).Count(&count).Error; err != nil {
-		panic(err)
+		return err
 	}
 	p.Total = count
 	p.MaxPage = int64(math.Ceil(float64(count) / float64(p.PageSize)))
 	if p.MaxPage == 0 {
 		p.MaxPage = 1
 	}
+	return nil
 }
 
 // Find requests page information (total records and max page) and
 // executes the transaction. The Paginate struct is updated automatically, as
 // well as the destination slice given in NewPaginate().
 func (p *Paginator) Find() *gorm.DB {
-	p.updatePageInfo()
+	if err := p.updatePageInfo(); err != nil {
+		tx := p.db.Session(&gorm.Session{})
+		_ = tx.AddError(err)
+		return tx
+	}
 	return p.db.Scopes(paginateScope(p.CurrentPage, p.PageSize)).Find(p.Records)
 }
@@ -0,0 +1,278 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type primaryContextKey struct{}
+
+// stickiness records whether a write was executed with a ReadYourWrites context.
+type stickiness struct {
+	written int32
+}
+
+// replicaPolicies available values of "database.replicaPolicy".
+var replicaPolicies = map[string]func() dbresolver.Policy{
+	"random":      func() dbresolver.Policy { return dbresolver.RandomPolicy{} },
+	"round_robin": func() dbresolver.Policy { return &roundRobinPolicy{} },
+}
+
+// roundRobinPolicy picks replicas in turn.
+type roundRobinPolicy struct {
+	next uint64
+}
+
+func (p *roundRobinPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	return connPools[(atomic.AddUint64(&p.next, 1)-1)%uint64(len(connPools))]
+}
+
+// replicaSet the read replicas of a connection and their health.
+// Used as dbresolver policy, it only hands out healthy replicas.
+type replicaSet struct {
+	policy dbresolver.Policy
+	// pools the replicas followed by the primary fallback
+	pools     []*sql.DB
+	replicas  []*sql.DB
+	mu        sync.RWMutex
+	unhealthy map[gorm.ConnPool]bool
+	stop      chan struct{}
+}
+
+// WithPrimary return a context sending the queries run with it to the primary
+// database, for example to read a record right after writing it.
+//
+//  database.WithContext(database.WithPrimary(ctx), db).First(&user, "id = ?", id)
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// ReadYourWrites return a context sending the queries run with it to the primary
+// database once a write has been executed with it, typically installed once per request.
+func ReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickiness{}, &stickiness{})
+}
+
+// WithContext return db bound to ctx, and forced on the primary database when
+// ctx was made with WithPrimary or with ReadYourWrites after a write.
+// Repositories should use it instead of db.WithContext for replicas to apply.
+func WithContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	db = db.WithContext(ctx)
+	if usePrimary(ctx) {
+		db = db.Clauses(dbresolver.Write)
+	}
+	return db
+}
+
+// usePrimary tells whether the statement context requires the primary database.
+func usePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if forced, _ := ctx.Value(primaryContextKey{}).(bool); forced {
+		return true
+	}
+	if sticky, ok := ctx.Value(stickiness{}).(*stickiness); ok {
+		return atomic.LoadInt32(&sticky.written) == 1
+	}
+	return false
+}
+
+func markWritten(db *gorm.DB) {
+	if db.Statement.Context == nil {
+		return
+	}
+	if sticky, ok := db.Statement.Context.Value(stickiness{}).(*stickiness); ok {
+		atomic.StoreInt32(&sticky.written, 1)
+	}
+}
+
+// useReplicas register the replicas configured under the "replicas" setting of
+// the given connection, if any, so that reads go to the replicas and writes to the primary.
+//
+// Each replica is an object overriding the settings of the primary connection:
+//
+//  "replicas": [{"host": "replica-1"}, {"host": "replica-2", "port": 3307}]
+func useReplicas(db *gorm.DB, d dialect, name string) (*replicaSet, error) {
+	var configs []map[string]interface{}
+	if err := viper.UnmarshalKey(settingKey(name, "replicas"), &configs); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", settingKey(name, "replicas"), err)
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	policyName := viper.GetString(settingKey(name, "replicaPolicy"))
+	newPolicy, ok := replicaPolicies[policyName]
+	if !ok {
+		return nil, fmt.Errorf("replica policy %q not supported", policyName)
+	}
+
+	primary := db.ConnPool
+	if prepared, ok := primary.(*gorm.PreparedStmtDB); ok {
+		primary = prepared.ConnPool
+	}
+	set := &replicaSet{
+		policy:    newPolicy(),
+		unhealthy: map[gorm.ConnPool]bool{},
+		stop:      make(chan struct{}),
+	}
+
+	// the primary is registered as last replica, only used when no replica is healthy,
+	// dbresolver skipping the policy, thus health checks, when given a single replica
+	dialectors := make([]gorm.Dialector, 0, len(configs)+1)
+	for i, config := range configs {
+		dsn, err := d.buildDSN(name, config)
+		if err != nil {
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+		dialectors = append(dialectors, d.initializer(dsn))
+	}
+	dsn, err := d.buildDSN(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	dialectors = append(dialectors, d.initializer(dsn))
+
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: set})
+	// must be set before registering the plugin to apply to the replicas
+	resolver.SetMaxOpenConns(viper.GetInt(settingKey(name, "maxOpenConnections"))).
+		SetMaxIdleConns(viper.GetInt(settingKey(name, "maxIdleConnections"))).
+		SetConnMaxLifetime(time.Duration(viper.GetInt(settingKey(name, "maxLifetime"))) * time.Second)
+	resolver.Call(func(connPool gorm.ConnPool) error {
+		if sqlDB, ok := connPool.(*sql.DB); ok && connPool != primary {
+			set.pools = append(set.pools, sqlDB)
+		}
+		return nil
+	})
+
+	if err := db.Use(resolver); err != nil {
+		return nil, err
+	}
+	set.replicas = set.pools[:len(set.pools)-1]
+	if err := set.registerCallbacks(db); err != nil {
+		return nil, err
+	}
+
+	go set.checkHealth(time.Duration(viper.GetInt(settingKey(name, "replicaHealthCheckInterval"))) * time.Second)
+
+	return set, nil
+}
+
+// registerCallbacks track the writes executed with a ReadYourWrites context.
+func (r *replicaSet) registerCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().After("gorm:create").Register("database:read_your_writes", markWritten),
+		callbacks.Update().After("gorm:update").Register("database:read_your_writes", markWritten),
+		callbacks.Delete().After("gorm:delete").Register("database:read_your_writes", markWritten),
+		callbacks.Raw().After("gorm:raw").Register("database:read_your_writes", func(db *gorm.DB) {
+			if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(db.Statement.SQL.String())), "SELECT") {
+				markWritten(db)
+			}
+		}),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve implements dbresolver.Policy, choosing among the healthy replicas
+// or falling back to the primary when none is healthy.
+// The last pool given by dbresolver is the primary fallback.
+func (r *replicaSet) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	fallback := connPools[len(connPools)-1]
+
+	r.mu.RLock()
+	healthy := make([]gorm.ConnPool, 0, len(connPools)-1)
+	for _, connPool := range connPools[:len(connPools)-1] {
+		if !r.unhealthy[connPool] {
+			healthy = append(healthy, connPool)
+		}
+	}
+	r.mu.RUnlock()
+
+	switch len(healthy) {
+	case 0:
+		return fallback
+	case 1:
+		return healthy[0]
+	}
+	return r.policy.Resolve(healthy)
+}
+
+// checkHealth ping the replicas every interval, removing the unreachable ones
+// from the rotation until they answer again.
+func (r *replicaSet) checkHealth(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		for i, replica := range r.replicas {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := replica.PingContext(ctx)
+			cancel()
+
+			r.mu.Lock()
+			if wasUnhealthy := r.unhealthy[replica]; err != nil && !wasUnhealthy {
+				log.Warnf("database replica %d removed from rotation: %s", i, err)
+			} else if err == nil && wasUnhealthy {
+				log.Infof("database replica %d back in rotation", i)
+			}
+			r.unhealthy[replica] = err != nil
+			r.mu.Unlock()
+		}
+	}
+}
+
+// close stop the health checks and close the replica connections.
+func (r *replicaSet) close() error {
+	close(r.stop)
+	var err error
+	for _, replica := range r.pools {
+		if closeErr := replica.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// replicaSetting value of a setting of the given connection, overridden by the replica configuration.
+func replicaSetting(override map[string]interface{}, name, key string) string {
+	if value, ok := overrideValue(override, key); ok {
+		return cast.ToString(value)
+	}
+	return viper.GetString(settingKey(name, key))
+}
+
+// overrideValue return the value of key in override, ignoring the case as viper does.
+func overrideValue(override map[string]interface{}, key string) (interface{}, bool) {
+	for k, v := range override {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
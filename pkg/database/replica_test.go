@@ -0,0 +1,61 @@
+package database_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/database"
+	_ "github.com/alpakih/go-api/pkg/database/dialect/sqlite"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	ID   uint
+	Body string
+}
+
+func TestReplicas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replicas")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the replica is a distinct database so reads can be told apart
+	for name, body := range map[string]string{"primary.db": "primary", "replica.db": "replica"} {
+		db, err := gorm.Open(sqlite.Open(filepath.Join(dir, name)), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&note{}))
+		require.NoError(t, db.Create(&note{ID: 1, Body: body}).Error)
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}
+
+	viper.Set("database.connection", "sqlite")
+	viper.Set("database.name", filepath.Join(dir, "primary.db"))
+	viper.Set("database.replicaPolicy", "round_robin")
+	viper.Set("database.replicas", []map[string]interface{}{{"name": filepath.Join(dir, "replica.db")}})
+	defer viper.Reset()
+	defer database.Close()
+
+	db, err := database.GetConnection()
+	require.NoError(t, err)
+	read := func(ctx context.Context) string {
+		var n note
+		require.NoError(t, database.WithContext(ctx, db).First(&n, 1).Error)
+		return n.Body
+	}
+
+	assert.Equal(t, "replica", read(context.Background()))
+	assert.Equal(t, "primary", read(database.WithPrimary(context.Background())))
+
+	ctx := database.ReadYourWrites(context.Background())
+	assert.Equal(t, "replica", read(ctx))
+	require.NoError(t, db.WithContext(ctx).Create(&note{ID: 2, Body: "written"}).Error)
+	assert.Equal(t, "primary", read(ctx))
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SeederFunc populates the database using the given transaction.
+// Seeders should be idempotent where they can, checking for existing
+// records before creating them, so seeding can be run several times.
+type SeederFunc func(tx *gorm.DB) error
+
+// Seeder a named unit of database seeding.
+type Seeder struct {
+	Name string
+	// Order among seeders not depending on each other, lowest first.
+	Order int
+	// DependsOn names of the seeders to run before this one.
+	DependsOn []string
+	// Environments the seeder runs in, all of them if empty.
+	Environments []string
+	Run          SeederFunc
+}
+
+// SeedOptions select the seeders to run.
+type SeedOptions struct {
+	// Only run the named seeders and their dependencies, all of them if empty.
+	Only []string
+	// Environment skip the seeders not made for this environment.
+	Environment string
+}
+
+var seeders = map[string]Seeder{}
+
+// RegisterSeeder register a seeder. Seeder names must be unique.
+func RegisterSeeder(seeder Seeder) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := seeders[seeder.Name]; ok {
+		panic(fmt.Sprintf("Seeder %q already exists", seeder.Name))
+	}
+	seeders[seeder.Name] = seeder
+}
+
+// ClearRegisteredSeeders unregister all seeders.
+func ClearRegisteredSeeders() {
+	mu.Lock()
+	defer mu.Unlock()
+	seeders = map[string]Seeder{}
+}
+
+// Seed run the selected seeders in dependency order inside a single transaction
+// and return the names of the seeders that ran.
+// Nothing is persisted if one of the seeders fails.
+func Seed(db *gorm.DB, options SeedOptions) ([]string, error) {
+	selected, err := ResolveSeeders(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, seeder := range selected {
+			if err := seeder.Run(tx); err != nil {
+				return fmt.Errorf("seeder %s: %w", seeder.Name, err)
+			}
+			ran = append(ran, seeder.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ran, nil
+}
+
+// ResolveSeeders return the seeders selected by the given options,
+// sorted so that every seeder comes after its dependencies.
+func ResolveSeeders(options SeedOptions) ([]Seeder, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	// select the requested seeders and, transitively, their dependencies
+	selected := map[string]Seeder{}
+	var selectSeeder func(name, dependent string) error
+	selectSeeder = func(name, dependent string) error {
+		if _, ok := selected[name]; ok {
+			return nil
+		}
+		seeder, ok := seeders[name]
+		if !ok {
+			if dependent != "" {
+				return fmt.Errorf("seeder %q depends on unknown seeder %q", dependent, name)
+			}
+			return fmt.Errorf("unknown seeder %q", name)
+		}
+		if !seeder.runsIn(options.Environment) {
+			if dependent != "" {
+				return fmt.Errorf("seeder %q depends on %q which does not run in environment %q", dependent, name, options.Environment)
+			}
+			return nil
+		}
+		selected[name] = seeder
+		for _, dependency := range seeder.DependsOn {
+			if err := selectSeeder(dependency, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	names := options.Only
+	if len(names) == 0 {
+		for name := range seeders {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if err := selectSeeder(name, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	// topological sort, picking the lowest order then name among ready seeders
+	pending := make(map[string]int, len(selected))
+	dependents := map[string][]string{}
+	var ready []Seeder
+	for name, seeder := range selected {
+		// a dependency listed twice is waited for once
+		dependencies := map[string]bool{}
+		for _, dependency := range seeder.DependsOn {
+			if !dependencies[dependency] {
+				dependencies[dependency] = true
+				dependents[dependency] = append(dependents[dependency], name)
+			}
+		}
+		pending[name] = len(dependencies)
+		if len(dependencies) == 0 {
+			ready = append(ready, seeder)
+		}
+	}
+
+	sorted := make([]Seeder, 0, len(selected))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			if ready[i].Order != ready[j].Order {
+				return ready[i].Order < ready[j].Order
+			}
+			return ready[i].Name < ready[j].Name
+		})
+		seeder := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, seeder)
+		for _, name := range dependents[seeder.Name] {
+			if pending[name]--; pending[name] == 0 {
+				ready = append(ready, selected[name])
+			}
+		}
+	}
+
+	if len(sorted) != len(selected) {
+		var blocked []string
+		for name, count := range pending {
+			if count > 0 {
+				blocked = append(blocked, name)
+			}
+		}
+		sort.Strings(blocked)
+		return nil, fmt.Errorf("seeders %s have circular dependencies", strings.Join(blocked, ", "))
+	}
+	return sorted, nil
+}
+
+func (s Seeder) runsIn(environment string) bool {
+	if len(s.Environments) == 0 {
+		return true
+	}
+	for _, env := range s.Environments {
+		if env == environment {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResolveSeeders(t *testing.T) {
+	defer ClearRegisteredSeeders()
+	RegisterSeeder(Seeder{Name: "posts", DependsOn: []string{"users"}})
+	RegisterSeeder(Seeder{Name: "users", Order: 1})
+	RegisterSeeder(Seeder{Name: "roles"})
+	RegisterSeeder(Seeder{Name: "fake_posts", DependsOn: []string{"posts"}, Environments: []string{"dev"}})
+
+	names := func(seeders []Seeder) []string {
+		result := make([]string, 0, len(seeders))
+		for _, seeder := range seeders {
+			result = append(result, seeder.Name)
+		}
+		return result
+	}
+
+	t.Run("all", func(t *testing.T) {
+		seeders, err := ResolveSeeders(SeedOptions{Environment: "dev"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"roles", "users", "posts", "fake_posts"}, names(seeders))
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		seeders, err := ResolveSeeders(SeedOptions{Environment: "production"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"roles", "users", "posts"}, names(seeders))
+	})
+
+	t.Run("only", func(t *testing.T) {
+		seeders, err := ResolveSeeders(SeedOptions{Only: []string{"posts"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"users", "posts"}, names(seeders))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := ResolveSeeders(SeedOptions{Only: []string{"comments"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("circular", func(t *testing.T) {
+		RegisterSeeder(Seeder{Name: "a", DependsOn: []string{"b"}})
+		RegisterSeeder(Seeder{Name: "b", DependsOn: []string{"a"}})
+		_, err := ResolveSeeders(SeedOptions{Only: []string{"a"}})
+		assert.EqualError(t, err, "seeders a, b have circular dependencies")
+	})
+
+	t.Run("duplicate dependency", func(t *testing.T) {
+		RegisterSeeder(Seeder{Name: "comments", DependsOn: []string{"posts", "users", "posts"}})
+		seeders, err := ResolveSeeders(SeedOptions{Only: []string{"comments"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"users", "posts", "comments"}, names(seeders))
+	})
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+// PoolStats usage of the connection pool of an open connection or replica.
+type PoolStats struct {
+	// Connection name of the connection, suffixed with "/replica{n}" for replicas.
+	Connection   string
+	Open         int
+	InUse        int
+	Idle         int
+	WaitCount    int64
+	WaitDuration time.Duration
+}
+
+// Stats return the pool usage of the open connections and their replicas, sorted by name.
+func Stats() []PoolStats {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var stats []PoolStats
+	for name, conn := range connections {
+		if sqlDb, err := conn.db.DB(); err == nil {
+			stats = append(stats, poolStats(name, sqlDb.Stats()))
+		}
+		if conn.replicas != nil {
+			for i, replica := range conn.replicas.replicas {
+				stats = append(stats, poolStats(fmt.Sprintf("%s/replica%d", name, i), replica.Stats()))
+			}
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Connection < stats[j].Connection
+	})
+	return stats
+}
+
+func poolStats(name string, stats sql.DBStats) PoolStats {
+	return PoolStats{
+		Connection:   name,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration,
+	}
+}
+
+// CollectStats call report with the pool usage every interval, until ctx is done.
+//
+//  go database.CollectStats(ctx, time.Minute, database.LogStats)
+func CollectStats(ctx context.Context, interval time.Duration, report func([]PoolStats)) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report(Stats())
+		}
+	}
+}
+
+// LogStats report the pool usage to the log.
+func LogStats(stats []PoolStats) {
+	for _, s := range stats {
+		log.Infof("database pool %s: open=%d in_use=%d idle=%d wait_count=%d wait_duration=%s",
+			s.Connection, s.Open, s.InUse, s.Idle, s.WaitCount, s.WaitDuration)
+	}
+}
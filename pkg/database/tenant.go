@@ -0,0 +1,303 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// ErrTenantNotFound returned for a tenant missing from the tenants table.
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// Tenant a tenant of the schema-per-tenant mode, whose tables live in its own Postgres schema.
+type Tenant struct {
+	ID        string    `gorm:"primaryKey;type:varchar(50)" json:"id"`
+	Schema    string    `gorm:"type:varchar(63);not null;uniqueIndex" json:"schema"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName the tenants table, in the public schema whatever the search path.
+func (Tenant) TableName() string {
+	return "public.tenants"
+}
+
+// tenantContextKey key of the tenant and its connection in a context.
+type tenantContextKey struct{}
+
+type tenantConnection struct {
+	tenant Tenant
+	db     *gorm.DB
+}
+
+var (
+	validTenantID    = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+	sharedModelTypes = map[reflect.Type]bool{}
+)
+
+// SchemaPerTenant tells whether "database.tenancy.mode" is "schema": each tenant of
+// the default connection, which must be Postgres, has its own schema. Tables of the
+// models registered with RegisterModel are created in every tenant schema, and
+// requests run their queries on a connection whose search path is the schema of
+// their tenant, followed by the public schema.
+func SchemaPerTenant() bool {
+	return viper.GetString("database.tenancy.mode") == "schema"
+}
+
+// RegisterSharedModel register a model of the default connection whose table is shared
+// by all tenants in the public schema, such as the outbox. Without schema per tenant
+// it is the same as RegisterModel.
+func RegisterSharedModel(model interface{}) {
+	sharedModelTypes[modelType(model)] = true
+	RegisterModel(model)
+}
+
+// IsSharedModel report whether the model was registered with RegisterSharedModel.
+func IsSharedModel(model interface{}) bool {
+	return sharedModelTypes[modelType(model)]
+}
+
+func modelType(model interface{}) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// TenantSchema return the schema of the given tenant.
+func TenantSchema(id string) string {
+	return "tenant_" + id
+}
+
+// Tenants return the tenants sorted by ID.
+func Tenants(ctx context.Context) ([]Tenant, error) {
+	db, err := GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	var tenants []Tenant
+	if err := db.WithContext(ctx).Order("id").Find(&tenants).Error; err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// FindTenant return the tenant of the given ID, ErrTenantNotFound if there is none.
+func FindTenant(ctx context.Context, id string) (Tenant, error) {
+	var tenant Tenant
+	if !validTenantID.MatchString(id) {
+		return tenant, ErrTenantNotFound
+	}
+	db, err := GetConnection()
+	if err != nil {
+		return tenant, err
+	}
+	if err := db.WithContext(ctx).First(&tenant, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tenant, ErrTenantNotFound
+		}
+		return tenant, err
+	}
+	return tenant, nil
+}
+
+// CreateTenant provision a tenant: create its schema, its tables, and apply the
+// versioned migrations to it. The tenant is dropped again when any step fails.
+// IDs are made of lower case letters, digits and underscores, starting with a letter.
+func CreateTenant(ctx context.Context, id string) (Tenant, error) {
+	tenant := Tenant{ID: id, Schema: TenantSchema(id)}
+	if !validTenantID.MatchString(id) {
+		return tenant, fmt.Errorf("invalid tenant ID %q", id)
+	}
+	db, err := tenancyConnection()
+	if err != nil {
+		return tenant, err
+	}
+	if err := db.WithContext(ctx).AutoMigrate(&Tenant{}); err != nil {
+		return tenant, err
+	}
+
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tenant).Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE SCHEMA " + quoteIdentifier(tenant.Schema)).Error
+	}); err != nil {
+		return tenant, fmt.Errorf("create tenant %s: %w", id, err)
+	}
+
+	if err := migrateTenant(ctx, db, tenant); err != nil {
+		if dropErr := DropTenant(ctx, id); dropErr != nil {
+			log.Errorf("drop tenant %s after its failed migration: %s", id, dropErr)
+		}
+		return tenant, fmt.Errorf("migrate tenant %s: %w", id, err)
+	}
+	return tenant, nil
+}
+
+// DropTenant deprovision a tenant, dropping its schema and all its data.
+func DropTenant(ctx context.Context, id string) error {
+	tenant, err := FindTenant(ctx, id)
+	if err != nil {
+		return err
+	}
+	db, err := tenancyConnection()
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP SCHEMA IF EXISTS " + quoteIdentifier(tenant.Schema) + " CASCADE").Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Tenant{}, "id = ?", id).Error
+	})
+}
+
+// WithTenant return a context whose queries run in the schema of the given tenant,
+// on a connection of the default connection pool held until release is called.
+// Repositories pick the connection up with FromContext, and WithinTransaction
+// begins its transactions on it.
+//
+//  ctx, release, err := database.WithTenant(ctx, "acme")
+//  if err != nil {
+//      return err
+//  }
+//  defer release()
+func WithTenant(ctx context.Context, id string) (context.Context, func(), error) {
+	tenant, err := FindTenant(ctx, id)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	db, err := tenancyConnection()
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	return withTenant(ctx, db, tenant)
+}
+
+func withTenant(ctx context.Context, db *gorm.DB, tenant Tenant) (context.Context, func(), error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	if _, err := conn.ExecContext(ctx, "SET search_path TO "+quoteIdentifier(tenant.Schema)+", public"); err != nil {
+		conn.Close()
+		return ctx, func() {}, err
+	}
+
+	release := func() {
+		// the request context may be done, the connection must be reset anyway
+		if _, err := conn.ExecContext(context.Background(), "RESET search_path"); err != nil {
+			log.Errorf("reset search path of tenant %s: %s", tenant.ID, err)
+			// discard the connection rather than leaving the tenant schema on it
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+
+	tenantDB := db.Session(&gorm.Session{Context: ctx})
+	tenantDB.Statement.ConnPool = conn
+	return context.WithValue(ctx, tenantContextKey{}, &tenantConnection{tenant: tenant, db: tenantDB}), release, nil
+}
+
+// TenantFromContext return the tenant of a context made by WithTenant.
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	if tc, ok := ctx.Value(tenantContextKey{}).(*tenantConnection); ok {
+		return tc.tenant, true
+	}
+	return Tenant{}, false
+}
+
+// tenantDB return the tenant connection of ctx bound to it.
+func tenantDB(ctx context.Context) (*gorm.DB, bool) {
+	if tc, ok := ctx.Value(tenantContextKey{}).(*tenantConnection); ok {
+		return tc.db.WithContext(ctx), true
+	}
+	return nil, false
+}
+
+// ForEachTenant call fn with a context in the schema of each tenant, in turn.
+func ForEachTenant(ctx context.Context, fn func(ctx context.Context, tenant Tenant) error) error {
+	tenants, err := Tenants(ctx)
+	if err != nil {
+		return err
+	}
+	db, err := tenancyConnection()
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants {
+		if err := forTenant(ctx, db, tenant, fn); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+	}
+	return nil
+}
+
+func forTenant(ctx context.Context, db *gorm.DB, tenant Tenant, fn func(ctx context.Context, tenant Tenant) error) error {
+	tenantCtx, release, err := withTenant(ctx, db, tenant)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(tenantCtx, tenant)
+}
+
+// migrateTenant create the tables of the tenant models and apply the versioned migrations.
+func migrateTenant(ctx context.Context, db *gorm.DB, tenant Tenant) error {
+	return forTenant(ctx, db, tenant, func(ctx context.Context, tenant Tenant) error {
+		if err := autoMigrateTenant(ctx, tenant); err != nil {
+			return err
+		}
+		migrator, err := DefaultMigrator()
+		if err != nil {
+			return err
+		}
+		_, err = migrator.Up(ctx, 0)
+		return err
+	})
+}
+
+// autoMigrateTenant migrate the models registered for the default connection,
+// other than the shared ones, in the schema of the tenant of ctx.
+func autoMigrateTenant(ctx context.Context, tenant Tenant) error {
+	db, _ := tenantDB(ctx)
+	for _, model := range models[DefaultConnection] {
+		if sharedModelTypes[modelType(model)] {
+			continue
+		}
+		if err := db.AutoMigrate(model); err != nil {
+			return fmt.Errorf("tenant %s: migrate %T: %w", tenant.ID, model, err)
+		}
+	}
+	return nil
+}
+
+// tenancyConnection return the default connection, checking it supports schema per tenant.
+func tenancyConnection() (*gorm.DB, error) {
+	if !SchemaPerTenant() {
+		return nil, errors.New(`schema per tenant requires "database.tenancy.mode" to be "schema"`)
+	}
+	if dialect := DialectName(DefaultConnection); dialect != "postgres" {
+		return nil, fmt.Errorf("schema per tenant is not supported by %s", dialect)
+	}
+	return GetConnection()
+}
+
+// quoteIdentifier quote a Postgres identifier.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,105 @@
+package database_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/database"
+	_ "github.com/alpakih/go-api/pkg/database/dialect/all"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantNote struct {
+	ID   uint
+	Body string
+}
+
+type sharedNote struct {
+	ID     uint
+	Tenant string
+}
+
+func TestSchemaPerTenantRequiresPostgres(t *testing.T) {
+	viper.Set("database.connection", "sqlite")
+	viper.Set("database.name", ":memory:")
+	viper.Set("database.tenancy.mode", "schema")
+	defer viper.Reset()
+	defer database.Reset()
+	defer database.Close()
+
+	_, err := database.CreateTenant(context.Background(), "acme")
+	assert.EqualError(t, err, "schema per tenant is not supported by sqlite")
+
+	_, err = database.CreateTenant(context.Background(), `acme"; DROP SCHEMA public; --`)
+	assert.Error(t, err)
+}
+
+// TestSchemaPerTenant runs against the Postgres database of TEST_POSTGRES_DSN.
+func TestSchemaPerTenant(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	viper.Set("database.connection", "postgres")
+	viper.Set("database.options", dsn)
+	viper.Set("database.maxOpenConnections", 5)
+	viper.Set("database.maxIdleConnections", 5)
+	viper.Set("database.migrations.dir", t.TempDir())
+	viper.Set("database.tenancy.mode", "schema")
+	defer viper.Reset()
+	defer database.Reset()
+	defer database.Close()
+
+	database.RegisterModel(tenantNote{})
+	database.RegisterSharedModel(sharedNote{})
+	require.NoError(t, database.Migrate())
+
+	ctx := context.Background()
+	for _, id := range []string{"acme", "globex"} {
+		_ = database.DropTenant(ctx, id)
+		_, err := database.CreateTenant(ctx, id)
+		require.NoError(t, err)
+		defer database.DropTenant(ctx, id)
+	}
+	require.NoError(t, database.Migrate())
+
+	db, err := database.GetConnection()
+	require.NoError(t, err)
+
+	acmeCtx, release, err := database.WithTenant(ctx, "acme")
+	require.NoError(t, err)
+	tenant, ok := database.TenantFromContext(acmeCtx)
+	require.True(t, ok)
+	assert.Equal(t, "tenant_acme", tenant.Schema)
+	require.NoError(t, database.FromContext(acmeCtx, db).Create(&tenantNote{Body: "first"}).Error)
+	require.NoError(t, database.WithinTransaction(acmeCtx, func(ctx context.Context) error {
+		if err := database.FromContext(ctx, db).Create(&tenantNote{Body: "second"}).Error; err != nil {
+			return err
+		}
+		return database.FromContext(ctx, db).Create(&sharedNote{Tenant: "acme"}).Error
+	}))
+	release()
+
+	globexCtx, release, err := database.WithTenant(ctx, "globex")
+	require.NoError(t, err)
+	var count int64
+	require.NoError(t, database.FromContext(globexCtx, db).Model(&tenantNote{}).Count(&count).Error)
+	assert.Zero(t, count, "tenants do not see each other rows")
+	require.NoError(t, database.FromContext(globexCtx, db).Model(&sharedNote{}).Where("tenant = ?", "acme").Count(&count).Error)
+	assert.Equal(t, int64(1), count, "shared tables are in the public schema")
+	release()
+
+	acmeCtx, release, err = database.WithTenant(ctx, "acme")
+	require.NoError(t, err)
+	require.NoError(t, database.FromContext(acmeCtx, db).Model(&tenantNote{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+	release()
+
+	assert.False(t, db.Migrator().HasTable(&tenantNote{}), "the search path is reset on release")
+
+	_, _, err = database.WithTenant(ctx, "missing")
+	assert.ErrorIs(t, err, database.ErrTenantNotFound)
+}
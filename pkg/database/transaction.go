@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// RetryClassifier tells whether a transaction failed with a transient error,
+// such as a deadlock or a serialization failure, and can be run again.
+type RetryClassifier func(err error) bool
+
+// txContextKey key of the transaction of a connection in a context.
+type txContextKey struct {
+	connection string
+}
+
+var retryClassifiers = map[string]RetryClassifier{}
+
+// RegisterRetryClassifier register the function telling which errors of the
+// given dialect make WithinTransaction run the transaction again.
+func RegisterRetryClassifier(name string, classifier RetryClassifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := retryClassifiers[name]; ok {
+		panic(fmt.Sprintf("Retry classifier for dialect %q already exists", name))
+	}
+	retryClassifiers[name] = classifier
+}
+
+// WithinTransaction run fn in a transaction of the default connection, committed
+// when fn returns nil and rolled back otherwise. The transaction is carried by
+// the context given to fn, where repositories pick it up with FromContext.
+//
+// Called with a context already carrying a transaction, fn runs in a savepoint
+// of that transaction instead, only rolled back to on error.
+//
+// A transaction failing with a deadlock or serialization error is run again,
+// up to "transaction.maxRetries" times, waiting "transaction.retryInterval"
+// milliseconds doubled on each attempt. fn must therefore be safe to run again.
+//
+//  err := database.WithinTransaction(ctx, func(ctx context.Context) error {
+//      if err := users.Store(ctx, user); err != nil {
+//          return err
+//      }
+//      return audits.Store(ctx, audit)
+//  })
+func WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return WithinNamedTransaction(ctx, DefaultConnection, fn)
+}
+
+// WithinNamedTransaction same as WithinTransaction on the given connection.
+func WithinNamedTransaction(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	run := func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txContextKey{name}, tx))
+	}
+
+	if tx, ok := ctx.Value(txContextKey{name}).(*gorm.DB); ok {
+		return tx.WithContext(ctx).Transaction(run)
+	}
+
+	db, err := GetNamedConnection(name)
+	if err != nil {
+		return err
+	}
+	if tenant, ok := tenantDB(ctx); ok && name == DefaultConnection {
+		db = tenant
+	}
+	retryable := retryClassifiers[DialectName(name)]
+	maxRetries := viper.GetInt(settingKey(name, "transaction.maxRetries"))
+	interval := time.Duration(viper.GetInt(settingKey(name, "transaction.retryInterval"))) * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		err = WithContext(ctx, db).Transaction(run)
+		if err == nil || retryable == nil || !retryable(err) || attempt > maxRetries {
+			return err
+		}
+
+		log.Warnf("database connection %s: transaction attempt %d failed, retrying in %s: %s", name, attempt, interval, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// FromContext return the transaction of the default connection carried by ctx,
+// or fallback when there is none, bound to ctx. Repositories use it so that
+// their queries join the transaction started by WithinTransaction.
+//
+//  database.FromContext(ctx, m.DB).Create(&user)
+func FromContext(ctx context.Context, fallback *gorm.DB) *gorm.DB {
+	return NamedFromContext(ctx, DefaultConnection, fallback)
+}
+
+// NamedFromContext same as FromContext for the transaction of the given connection.
+// Without a transaction, the default connection falls back to the tenant connection
+// of a context made by WithTenant.
+func NamedFromContext(ctx context.Context, name string, fallback *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txContextKey{name}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	if tenant, ok := tenantDB(ctx); ok && name == DefaultConnection {
+		return tenant
+	}
+	return WithContext(ctx, fallback)
+}
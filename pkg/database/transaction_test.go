@@ -0,0 +1,105 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/database"
+	sqlitedialect "github.com/alpakih/go-api/pkg/database/dialect/sqlite"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+)
+
+var errConflict = errors.New("conflict")
+
+func init() {
+	// a sqlite dialect whose errConflict errors are retryable
+	database.RegisterDialect("retrying_sqlite", sqlitedialect.BuildDSN, sqlite.Open)
+	database.RegisterRetryClassifier("retrying_sqlite", func(err error) bool {
+		return errors.Is(err, errConflict)
+	})
+}
+
+func TestWithinTransaction(t *testing.T) {
+	viper.Set("database.connection", "retrying_sqlite")
+	viper.Set("database.name", "transactions")
+	viper.Set("database.options", "mode=memory&cache=shared")
+	viper.Set("database.maxOpenConnections", 1)
+	viper.Set("database.maxIdleConnections", 1)
+	viper.Set("database.transaction.maxRetries", 2)
+	defer viper.Reset()
+	defer database.Reset()
+	defer database.Close()
+
+	database.RegisterModel(note{})
+	require.NoError(t, database.Migrate())
+	db, err := database.GetConnection()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	count := func() int64 {
+		var n int64
+		require.NoError(t, db.Model(&note{}).Count(&n).Error)
+		return n
+	}
+	create := func(ctx context.Context, body string) error {
+		return database.FromContext(ctx, db).Create(&note{Body: body}).Error
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		require.NoError(t, database.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := create(ctx, "user"); err != nil {
+				return err
+			}
+			return create(ctx, "audit")
+		}))
+		assert.Equal(t, int64(2), count())
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		err := database.WithinTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, create(ctx, "user"))
+			return errors.New("audit failed")
+		})
+		assert.EqualError(t, err, "audit failed")
+		assert.Equal(t, int64(2), count())
+	})
+
+	t.Run("savepoint", func(t *testing.T) {
+		require.NoError(t, database.WithinTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, create(ctx, "kept"))
+			err := database.WithinTransaction(ctx, func(ctx context.Context) error {
+				require.NoError(t, create(ctx, "discarded"))
+				return errors.New("nested failed")
+			})
+			assert.EqualError(t, err, "nested failed")
+			return nil
+		}))
+		assert.Equal(t, int64(3), count())
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		attempts := 0
+		require.NoError(t, database.WithinTransaction(ctx, func(ctx context.Context) error {
+			attempts++
+			require.NoError(t, create(ctx, "retried"))
+			if attempts < 3 {
+				return errConflict
+			}
+			return nil
+		}))
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, int64(4), count())
+
+		attempts = 0
+		err := database.WithinTransaction(ctx, func(ctx context.Context) error {
+			attempts++
+			return errConflict
+		})
+		assert.ErrorIs(t, err, errConflict)
+		assert.Equal(t, 3, attempts, "one attempt and two retries")
+	})
+}
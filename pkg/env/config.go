@@ -17,6 +17,12 @@ func LoadEnvironment() {
 	// Enable VIPER to read Environment Variables
 	viper.AutomaticEnv()
 
+	// Database credentials from the environment, or from secret files
+	_ = viper.BindEnv("database.url", "DATABASE_URL")
+	_ = viper.BindEnv("database.urlFile", "DATABASE_URL_FILE")
+	_ = viper.BindEnv("database.usernameFile", "DATABASE_USERNAME_FILE")
+	_ = viper.BindEnv("database.passwordFile", "DATABASE_PASSWORD_FILE")
+
 	viper.SetConfigType("json")
 
 	if err := viper.ReadInConfig(); err != nil {
@@ -27,11 +33,40 @@ func LoadEnvironment() {
 	// Set undefined variables
 	viper.SetDefault("app.debug", true)
 	viper.SetDefault("app.bcryptCost", 10)
+	viper.SetDefault("app.defaultLanguage", "en-US")
 	viper.SetDefault("server.host", "localhost")
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("database.maxOpenConnections", 20)
 	viper.SetDefault("database.maxIdleConnections", 20)
 	viper.SetDefault("database.maxLifetime", 300)
 	viper.SetDefault("database.autoMigrate", false)
+	viper.SetDefault("database.replicaPolicy", "random")
+	viper.SetDefault("database.replicaHealthCheckInterval", 10)
+	viper.SetDefault("database.migrations.dir", "./migrations")
+	viper.SetDefault("database.migrations.lockTimeout", 60)
+	viper.SetDefault("database.retry.timeout", 60)
+	viper.SetDefault("database.retry.initialInterval", 500)
+	viper.SetDefault("database.retry.maxInterval", 10000)
+	viper.SetDefault("database.statsInterval", 60)
+	viper.SetDefault("database.transaction.maxRetries", 3)
+	viper.SetDefault("database.transaction.retryInterval", 50)
+	viper.SetDefault("database.log.slowThreshold", 200)
+	viper.SetDefault("database.log.nPlusOneThreshold", 10)
+	viper.SetDefault("database.tenancy.header", "X-Tenant-ID")
+	viper.SetDefault("seed.users.count", 20)
+	viper.SetDefault("outbox.sink", "log")
+	viper.SetDefault("outbox.webhook.timeout", 10)
+	viper.SetDefault("outbox.relay.interval", 1000)
+	viper.SetDefault("outbox.relay.batchSize", 100)
+	viper.SetDefault("outbox.relay.initialBackoff", 1000)
+	viper.SetDefault("outbox.relay.maxBackoff", 300000)
+	viper.SetDefault("outbox.relay.lease", 300000)
+	viper.SetDefault("encryption.currentKey", 1)
+	viper.SetDefault("password.minLength", 8)
+	viper.SetDefault("password.requireLower", true)
+	viper.SetDefault("password.requireDigit", true)
+	viper.SetDefault("password.disallowUsername", true)
+	viper.SetDefault("password.minScore", 2)
+	viper.SetDefault("password.checkBreached", true)
 
 }
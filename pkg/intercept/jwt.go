@@ -7,6 +7,9 @@ import (
 	"strings"
 )
 
+// UserContextKey key of the *jwt.Token of the authenticated user in the echo context.
+const UserContextKey = "user"
+
 type jwt struct {
 	secretKey string
 }
@@ -26,7 +29,7 @@ func (m *jwt) JwtConfig() middleware.JWTConfig {
 		},
 		SigningKey:    []byte(m.secretKey),
 		SigningMethod: middleware.AlgorithmHS256,
-		ContextKey:    "user",
+		ContextKey:    UserContextKey,
 		TokenLookup:   "header:Authorization",
 		AuthScheme:    "Bearer",
 	}
@@ -0,0 +1,21 @@
+package intercept
+
+import (
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Language middleware picking the language of the validation messages from the
+// Accept-Language header, "app.defaultLanguage" when no supported language matches.
+// The language picked is sent back in the Content-Language header.
+func Language() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			request := ctx.Request()
+			lang := validation.MatchLanguage(request.Header.Get("Accept-Language"))
+			ctx.SetRequest(request.WithContext(validation.WithLanguage(request.Context(), lang)))
+			ctx.Response().Header().Set("Content-Language", lang)
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package intercept
+
+import (
+	"fmt"
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"strconv"
+)
+
+// QueryStats middleware counting the database queries of each request, reporting the
+// queries run repeatedly as possible N+1. With "app.debug" the number of queries and the
+// time spent running them are sent in the "X-Query-Count" and "X-Query-Time" (ms) headers.
+func QueryStats() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			request := ctx.Request()
+			requestCtx, stats := database.WithQueryStats(request.Context(), request.Method+" "+ctx.Path())
+			ctx.SetRequest(request.WithContext(requestCtx))
+
+			if viper.GetBool("app.debug") {
+				response := ctx.Response()
+				response.Before(func() {
+					response.Header().Set("X-Query-Count", strconv.Itoa(stats.Count()))
+					response.Header().Set("X-Query-Time", fmt.Sprintf("%.3f", float64(stats.Duration().Microseconds())/1000))
+				})
+			}
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package intercept
+
+import (
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/labstack/echo/v4"
+)
+
+// ReadYourWrites middleware sending the reads of a request to the primary database
+// once the request has written to it, so it never reads stale data from a replica.
+func ReadYourWrites() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			request := ctx.Request()
+			ctx.SetRequest(request.WithContext(database.ReadYourWrites(request.Context())))
+			return next(ctx)
+		}
+	}
+}
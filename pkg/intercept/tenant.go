@@ -0,0 +1,55 @@
+package intercept
+
+import (
+	"errors"
+	"github.com/alpakih/go-api/pkg/database"
+	gojwt "github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"net/http"
+)
+
+// TenantClaim claim of the access tokens holding the ID of the tenant they were
+// issued for.
+const TenantClaim = "tenant"
+
+// Tenant middleware running the database queries of a request in the schema of its
+// tenant, when database.SchemaPerTenant. It runs after the JWT middleware: the tenant
+// of an authenticated request is the one of its token, the "database.tenancy.header"
+// header being rejected when it names another one, and only the requests skipped by
+// the JWT middleware, such as the token request, name their tenant by the header.
+// The connection of the tenant is held until the response is written.
+func Tenant() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !database.SchemaPerTenant() {
+				return next(ctx)
+			}
+			id := ctx.Request().Header.Get(viper.GetString("database.tenancy.header"))
+			if token, ok := ctx.Get(UserContextKey).(*gojwt.Token); ok {
+				claims, _ := token.Claims.(gojwt.MapClaims)
+				claim, _ := claims[TenantClaim].(string)
+				if claim == "" || (id != "" && id != claim) {
+					return ctx.JSON(http.StatusForbidden, echo.Map{"message": http.StatusText(http.StatusForbidden)})
+				}
+				id = claim
+			}
+			if id == "" {
+				return ctx.JSON(http.StatusBadRequest, echo.Map{"message": "missing tenant"})
+			}
+
+			request := ctx.Request()
+			tenantCtx, release, err := database.WithTenant(request.Context(), id)
+			if errors.Is(err, database.ErrTenantNotFound) {
+				return ctx.JSON(http.StatusNotFound, echo.Map{"message": "tenant not found"})
+			}
+			if err != nil {
+				ctx.Logger().Error(err)
+				return ctx.JSON(http.StatusServiceUnavailable, echo.Map{"message": http.StatusText(http.StatusServiceUnavailable)})
+			}
+			defer release()
+			ctx.SetRequest(request.WithContext(tenantCtx))
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package intercept
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gojwt "github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantRejectsOtherTenants(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("database.tenancy.mode", "schema")
+	viper.Set("database.tenancy.header", "X-Tenant-ID")
+
+	serve := func(header string, claims gojwt.MapClaims) int {
+		e := echo.New()
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		if header != "" {
+			request.Header.Set("X-Tenant-ID", header)
+		}
+		recorder := httptest.NewRecorder()
+		ctx := e.NewContext(request, recorder)
+		if claims != nil {
+			ctx.Set(UserContextKey, gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims))
+		}
+		handler := Tenant()(func(ctx echo.Context) error {
+			t.Fatal("the tenant is not checked")
+			return nil
+		})
+		assert.NoError(t, handler(ctx))
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve("b", gojwt.MapClaims{TenantClaim: "a"}), "header of another tenant")
+	assert.Equal(t, http.StatusForbidden, serve("a", gojwt.MapClaims{"username": "alice"}), "token without tenant")
+	assert.Equal(t, http.StatusBadRequest, serve("", nil), "anonymous request without tenant")
+}
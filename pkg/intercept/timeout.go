@@ -0,0 +1,26 @@
+package intercept
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// RequestTimeout middleware bounding the request context by "server.timeout" seconds,
+// cancelling the database queries of requests running longer or whose client went away.
+func RequestTimeout() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			timeout := time.Duration(viper.GetInt("server.timeout")) * time.Second
+			if timeout <= 0 {
+				return next(ctx)
+			}
+			request := ctx.Request()
+			requestCtx, cancel := context.WithTimeout(request.Context(), timeout)
+			defer cancel()
+			ctx.SetRequest(request.WithContext(requestCtx))
+			return next(ctx)
+		}
+	}
+}
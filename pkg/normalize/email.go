@@ -0,0 +1,9 @@
+package normalize
+
+import "strings"
+
+// Email returns the canonical form of an email address, trimmed and lower
+// cased, used to compute its blind index.
+func Email(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,11 @@
+package normalize
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	assert.Equal(t, "alice@example.com", Email(" Alice@Example.COM "))
+	assert.Equal(t, "", Email("  "))
+}
@@ -0,0 +1,25 @@
+package normalize
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Username returns the canonical form of a username, used for lookups and
+// uniqueness checks so that "Alice" and "alice " resolve to the same account.
+//
+// The pipeline trims surrounding whitespace, applies Unicode NFKC and case
+// folding, then NFKC again since folding may produce denormalized output.
+func Username(username string) string {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return ""
+	}
+
+	// a Caser is stateful and must not be shared between goroutines
+	folded := cases.Fold().String(norm.NFKC.String(username))
+
+	return norm.NFKC.String(folded)
+}
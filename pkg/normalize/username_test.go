@@ -0,0 +1,24 @@
+package normalize
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUsername(t *testing.T) {
+	cases := map[string]string{
+		"alice":   "alice",
+		"Alice":   "alice",
+		" alice ": "alice",
+		"ALICE\t": "alice",
+		"ｱlice":   "アlice",
+		"Ａｌｉｃｅ":   "alice",
+		"straße":  "strasse",
+		"Ångstr":  "ångstr",
+		"   ":     "",
+	}
+
+	for input, expected := range cases {
+		assert.Equal(t, expected, Username(input), "input %q", input)
+	}
+}
@@ -0,0 +1,122 @@
+// Package outbox implements the transactional outbox pattern: domain events are
+// written to the outbox_events table in the transaction of the change they
+// describe, then delivered to a Sink by a Relay polling the table.
+//
+// Delivery is at least once: consumers deduplicate events by their ID.
+//
+// With schema per tenant the outbox is a single table of the public schema, which
+// the application registers with database.RegisterSharedModel, and the events
+// carry the ID of the tenant they were published in.
+//
+//  err := database.WithinTransaction(ctx, func(ctx context.Context) error {
+//      if err := users.Store(ctx, user); err != nil {
+//          return err
+//      }
+//      event, err := outbox.New("user", user.ID, "user.created", user)
+//      if err != nil {
+//          return err
+//      }
+//      return outbox.Publish(ctx, event)
+//  })
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/google/uuid"
+	"gopkg.in/guregu/null.v4"
+)
+
+// ErrNotShared returned with schema per tenant when Event is not registered with
+// database.RegisterSharedModel, the events being split among the tenant schemas
+// where no relay reads them.
+var ErrNotShared = errors.New("outbox: Event must be registered with database.RegisterSharedModel with schema per tenant")
+
+// Event a domain event stored in the outbox until it is delivered.
+//
+// Events of the same aggregate are delivered in Sequence order.
+type Event struct {
+	Sequence      uint64    `gorm:"column:sequence;primaryKey;autoIncrement" json:"-"`
+	ID            string    `gorm:"column:event_id;type:varchar(36);uniqueIndex:idx_outbox_events_event_id" json:"id"`
+	AggregateType string    `gorm:"column:aggregate_type;type:varchar(100);index:idx_outbox_events_aggregate" json:"aggregate_type"`
+	AggregateID   string    `gorm:"column:aggregate_id;type:varchar(100);index:idx_outbox_events_aggregate" json:"aggregate_id"`
+	Type          string    `gorm:"column:type;type:varchar(100)" json:"type"`
+	Payload       string    `gorm:"column:payload;type:text" json:"-"`
+	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
+
+	// TenantID tenant the event was published in, with schema per tenant.
+	TenantID string `gorm:"column:tenant_id;type:varchar(50)" json:"tenant_id,omitempty"`
+
+	PublishedAt   null.Time `gorm:"column:published_at;index:idx_outbox_events_published_at" json:"-"`
+	Attempts      int       `gorm:"column:attempts" json:"-"`
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at" json:"-"`
+	LastError     string    `gorm:"column:last_error;type:text" json:"-"`
+
+	// LockedBy relay holding the event until LockedUntil, see Relay.
+	LockedBy    null.String `gorm:"column:locked_by;type:varchar(36)" json:"-"`
+	LockedUntil null.Time   `gorm:"column:locked_until" json:"-"`
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// MarshalJSON encodes the event as sent to the sinks, the payload embedded as JSON.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	return json.Marshal(struct {
+		event
+		Payload json.RawMessage `json:"payload"`
+	}{event(e), json.RawMessage(e.Payload)})
+}
+
+// New create an event of the given type about an aggregate, with a new ID and
+// the payload encoded as JSON.
+func New(aggregateType, aggregateID, eventType string, payload interface{}) (Event, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:            uuid.New().String(),
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Type:          eventType,
+		Payload:       string(encoded),
+	}, nil
+}
+
+// Publish write the events to the outbox, in the transaction carried by ctx
+// when there is one, see database.WithinTransaction. Without a transaction the
+// events are not written atomically with the change they describe. The events
+// are stamped with the tenant of ctx, if any.
+func Publish(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := checkShared(); err != nil {
+		return err
+	}
+	if tenant, ok := database.TenantFromContext(ctx); ok {
+		for i := range events {
+			events[i].TenantID = tenant.ID
+		}
+	}
+	db, err := database.GetConnection()
+	if err != nil {
+		return err
+	}
+	return database.FromContext(ctx, db).Create(&events).Error
+}
+
+// checkShared return ErrNotShared when the outbox would be a table of every tenant.
+func checkShared() error {
+	if database.SchemaPerTenant() && !database.IsSharedModel(Event{}) {
+		return ErrNotShared
+	}
+	return nil
+}
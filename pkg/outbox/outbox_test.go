@@ -0,0 +1,199 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/database/databasetest"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent(t *testing.T, aggregateID, eventType string) Event {
+	event, err := New("user", aggregateID, eventType, map[string]string{"id": aggregateID})
+	require.NoError(t, err)
+	return event
+}
+
+func TestPublish(t *testing.T) {
+	db := databasetest.New(t, Event{})
+	ctx := context.Background()
+
+	err := database.WithinTransaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, Publish(ctx, newEvent(t, "1", "user.created")))
+		return errors.New("user not stored")
+	})
+	require.Error(t, err)
+
+	require.NoError(t, database.WithinTransaction(ctx, func(ctx context.Context) error {
+		return Publish(ctx, newEvent(t, "2", "user.created"))
+	}))
+
+	var events []Event
+	require.NoError(t, db.Find(&events).Error)
+	require.Len(t, events, 1)
+	assert.Equal(t, "2", events[0].AggregateID)
+	assert.JSONEq(t, `{"id":"2"}`, events[0].Payload)
+}
+
+func TestSharedOutbox(t *testing.T) {
+	db := databasetest.New(t, Event{})
+	viper.Set("database.tenancy.mode", "schema")
+	defer viper.Set("database.tenancy.mode", nil)
+	defer database.ClearRegisteredModels()
+	ctx := context.Background()
+
+	assert.ErrorIs(t, Publish(ctx, newEvent(t, "1", "user.created")), ErrNotShared)
+	_, err := NewRelay(db, LogSink{}).RelayOnce(ctx)
+	assert.ErrorIs(t, err, ErrNotShared)
+
+	database.RegisterSharedModel(Event{})
+	require.NoError(t, Publish(ctx, newEvent(t, "1", "user.created")))
+	sent, err := NewRelay(db, LogSink{}).RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+}
+
+func TestRelay(t *testing.T) {
+	db := databasetest.New(t, Event{})
+	ctx := context.Background()
+
+	a1, a2, b1 := newEvent(t, "a", "user.created"), newEvent(t, "a", "user.deleted"), newEvent(t, "b", "user.created")
+	require.NoError(t, Publish(ctx, a1, a2, b1))
+
+	var sent []string
+	failures := map[string]int{a1.ID: 1}
+	relay := NewRelay(db, SinkFunc(func(ctx context.Context, event Event) error {
+		if failures[event.ID] > 0 {
+			failures[event.ID]--
+			return errors.New("unavailable")
+		}
+		sent = append(sent, event.ID)
+		return nil
+	}))
+	relay.InitialBackoff = 20 * time.Millisecond
+
+	delivered, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{b1.ID}, sent, "the events of aggregate a wait for the failed one")
+
+	delivered, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered, "the failed event waits for its backoff")
+
+	time.Sleep(relay.InitialBackoff)
+	delivered, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	assert.Equal(t, []string{b1.ID, a1.ID, a2.ID}, sent)
+
+	var failed Event
+	require.NoError(t, db.First(&failed, "event_id = ?", a1.ID).Error)
+	assert.Equal(t, 2, failed.Attempts)
+	assert.Equal(t, "unavailable", failed.LastError)
+	assert.True(t, failed.PublishedAt.Valid)
+}
+
+func TestRelayDoesNotStarveOtherAggregates(t *testing.T) {
+	db := databasetest.New(t, Event{})
+	ctx := context.Background()
+
+	a1, a2, a3, b1 := newEvent(t, "a", "user.created"), newEvent(t, "a", "user.updated"),
+		newEvent(t, "a", "user.deleted"), newEvent(t, "b", "user.created")
+	require.NoError(t, Publish(ctx, a1, a2, a3, b1))
+
+	var sent []string
+	relay := NewRelay(db, SinkFunc(func(ctx context.Context, event Event) error {
+		if event.ID == a1.ID {
+			return errors.New("unavailable")
+		}
+		sent = append(sent, event.ID)
+		return nil
+	}))
+	relay.BatchSize = 2
+	relay.InitialBackoff = time.Minute
+
+	delivered, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+
+	delivered, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{b1.ID}, sent, "the backlog of aggregate a does not fill the batch")
+}
+
+func TestRelaysShareTheOutbox(t *testing.T) {
+	db := databasetest.New(t, Event{})
+	ctx := context.Background()
+
+	a1, a2, b1 := newEvent(t, "a", "user.created"), newEvent(t, "a", "user.deleted"), newEvent(t, "b", "user.created")
+	require.NoError(t, Publish(ctx, a1, a2, b1))
+
+	var sent []string
+	other := NewRelay(db, SinkFunc(func(ctx context.Context, event Event) error {
+		sent = append(sent, event.ID)
+		return nil
+	}))
+	relay := NewRelay(db, SinkFunc(func(ctx context.Context, event Event) error {
+		if event.ID == a1.ID {
+			delivered, err := other.RelayOnce(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 1, delivered, "the events of aggregate a are claimed, those of b are not")
+		}
+		sent = append(sent, event.ID)
+		return nil
+	}))
+	relay.BatchSize = 1
+
+	delivered, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	delivered, err = other.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{b1.ID, a1.ID, a2.ID}, sent)
+
+	var claimed int64
+	require.NoError(t, db.Model(&Event{}).Where("locked_by IS NOT NULL").Count(&claimed).Error)
+	assert.Zero(t, claimed, "the events are released")
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := &Relay{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, relay.backoff(1))
+	assert.Equal(t, 2*time.Second, relay.backoff(2))
+	assert.Equal(t, 4*time.Second, relay.backoff(3))
+	assert.Equal(t, 5*time.Second, relay.backoff(4))
+}
+
+func TestWebhookSink(t *testing.T) {
+	event := newEvent(t, "a", "user.created")
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, event.ID, r.Header.Get("Idempotency-Key"))
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, event.ID, body["id"])
+		assert.Equal(t, "user.created", body["type"])
+		assert.Equal(t, map[string]interface{}{"id": "a"}, body["payload"])
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := WebhookSink{URL: server.URL}
+	assert.NoError(t, sink.Send(context.Background(), event))
+
+	status = http.StatusInternalServerError
+	assert.Error(t, sink.Send(context.Background(), event))
+}
@@ -0,0 +1,221 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// Relay polls the outbox and delivers the pending events to a sink.
+//
+// A failed event is retried with an exponential backoff, and the following
+// events of its aggregate wait for it to be delivered, keeping their order.
+// Events of other aggregates are not held up.
+//
+// Each poll claims the events it delivers for the Lease duration, so that the
+// relays of several instances share the outbox without sending the same events,
+// the events of an aggregate being delivered by one relay at a time.
+type Relay struct {
+	db    *gorm.DB
+	sink  Sink
+	owner string
+
+	// Interval between two polls of the outbox.
+	Interval time.Duration
+	// BatchSize maximum number of events read by a poll.
+	BatchSize int
+	// InitialBackoff delay before the first retry of a failed event, doubled
+	// on each attempt up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Lease duration the events of a poll are claimed for, after which other
+	// relays may claim them again. It must exceed the time taken to deliver a batch.
+	Lease time.Duration
+}
+
+// NewRelay create a relay delivering the events of the outbox in db to sink,
+// configured by the "outbox.relay.*" settings.
+func NewRelay(db *gorm.DB, sink Sink) *Relay {
+	return &Relay{
+		db:             db,
+		sink:           sink,
+		owner:          uuid.New().String(),
+		Interval:       time.Duration(viper.GetInt("outbox.relay.interval")) * time.Millisecond,
+		BatchSize:      viper.GetInt("outbox.relay.batchSize"),
+		InitialBackoff: time.Duration(viper.GetInt("outbox.relay.initialBackoff")) * time.Millisecond,
+		MaxBackoff:     time.Duration(viper.GetInt("outbox.relay.maxBackoff")) * time.Millisecond,
+		Lease:          time.Duration(viper.GetInt("outbox.relay.lease")) * time.Millisecond,
+	}
+}
+
+// Run poll the outbox every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RelayOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Errorf("outbox relay: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce claim a batch of pending events, deliver them in sequence order, and
+// return the number of events delivered.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	if err := checkShared(); err != nil {
+		return 0, err
+	}
+	// the outbox is read from the primary, replicas lagging behind the deliveries
+	ctx = database.WithPrimary(ctx)
+	now := time.Now()
+	if err := r.claim(ctx, now); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := r.update(r.claimed(ctx), nil); err != nil && ctx.Err() == nil {
+			log.Errorf("outbox relay: release events: %s", err)
+		}
+	}()
+
+	// claimed events following an event of their aggregate claimed by another relay
+	// wait for it to be delivered
+	var events []Event
+	if err := r.claimed(ctx).
+		Where("NOT EXISTS (SELECT 1 FROM outbox_events earlier"+
+			" WHERE earlier.aggregate_type = outbox_events.aggregate_type AND earlier.aggregate_id = outbox_events.aggregate_id"+
+			" AND earlier.published_at IS NULL AND earlier.sequence < outbox_events.sequence"+
+			" AND (earlier.locked_by IS NULL OR earlier.locked_by <> ?))", r.owner).
+		Order("sequence").
+		Find(&events).Error; err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	// aggregates with an undelivered event, whose following events must wait
+	blocked := map[[2]string]bool{}
+	for _, event := range events {
+		aggregate := [2]string{event.AggregateType, event.AggregateID}
+		if blocked[aggregate] {
+			continue
+		}
+
+		now := time.Now()
+		if err := r.sink.Send(ctx, event); err != nil {
+			if ctx.Err() != nil {
+				return delivered, ctx.Err()
+			}
+			blocked[aggregate] = true
+			event.Attempts++
+			delay := r.backoff(event.Attempts)
+			log.Warnf("outbox event %s attempt %d failed, retrying in %s: %s", event.ID, event.Attempts, delay, err)
+			if err := r.update(r.event(ctx, event), map[string]interface{}{
+				"attempts":        event.Attempts,
+				"next_attempt_at": now.Add(delay),
+				"last_error":      err.Error(),
+			}); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		if err := r.update(r.event(ctx, event), map[string]interface{}{
+			"published_at": null.TimeFrom(now),
+			"attempts":     event.Attempts + 1,
+		}); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// claim lease to the relay the first pending events of the aggregates not waiting
+// for the backoff of a failed event nor claimed by another relay.
+func (r *Relay) claim(ctx context.Context, now time.Time) error {
+	batchSize := r.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	lease := r.Lease
+	if lease <= 0 {
+		lease = 5 * time.Minute
+	}
+
+	return database.WithContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var sequences []uint64
+		if err := tx.Model(&Event{}).
+			Where("published_at IS NULL").
+			Where("NOT EXISTS (SELECT 1 FROM outbox_events blocking"+
+				" WHERE blocking.aggregate_type = outbox_events.aggregate_type AND blocking.aggregate_id = outbox_events.aggregate_id"+
+				" AND blocking.published_at IS NULL AND (blocking.next_attempt_at > ? OR blocking.locked_until > ?))", now, now).
+			Order("sequence").
+			Limit(batchSize).
+			Pluck("sequence", &sequences).Error; err != nil {
+			return err
+		}
+		if len(sequences) == 0 {
+			return nil
+		}
+		// the condition is checked again by the update, events claimed by another
+		// relay in the meantime being left to it
+		return tx.Model(&Event{}).
+			Where("sequence IN ? AND published_at IS NULL", sequences).
+			Where("locked_until IS NULL OR locked_until <= ?", now).
+			UpdateColumns(map[string]interface{}{
+				"locked_by":    r.owner,
+				"locked_until": now.Add(lease),
+			}).Error
+	})
+}
+
+// claimed return a query of the pending events claimed by the relay.
+func (r *Relay) claimed(ctx context.Context) *gorm.DB {
+	return database.WithContext(ctx, r.db).Model(&Event{}).
+		Where("locked_by = ? AND published_at IS NULL", r.owner)
+}
+
+// event return a query of the given event.
+func (r *Relay) event(ctx context.Context, event Event) *gorm.DB {
+	return database.WithContext(ctx, r.db).Model(&Event{}).
+		Where("sequence = ?", event.Sequence)
+}
+
+// update set the given columns of the events of query, releasing them.
+func (r *Relay) update(query *gorm.DB, columns map[string]interface{}) error {
+	values := map[string]interface{}{"locked_by": nil, "locked_until": nil}
+	for column, value := range columns {
+		values[column] = value
+	}
+	return query.UpdateColumns(values).Error
+}
+
+// backoff delay before the given attempt.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.InitialBackoff
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if r.MaxBackoff > 0 && delay >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+)
+
+// Sink delivers events to their consumers.
+// An error makes the relay send the event again later.
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(ctx context.Context, event Event) error
+
+func (f SinkFunc) Send(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// LogSink writes the events to the log, useful in development.
+type LogSink struct{}
+
+func (LogSink) Send(_ context.Context, event Event) error {
+	log.Infof("outbox event %s %s %s/%s: %s", event.ID, event.Type, event.AggregateType, event.AggregateID, event.Payload)
+	return nil
+}
+
+// WebhookSink posts the events as JSON to an HTTP endpoint, with the event ID in
+// the "Idempotency-Key" header. Any status other than 2xx is an error.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s WebhookSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Idempotency-Key", event.ID)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook %s answered %s", s.URL, response.Status)
+	}
+	return nil
+}
+
+// Broker a message broker client, such as an adapter of a NATS connection
+// or a Kafka producer.
+type Broker interface {
+	Publish(ctx context.Context, subject string, data []byte, headers map[string]string) error
+}
+
+// BrokerSink publishes the events as JSON to a message broker, on the subject
+// named after the event type, with the event ID in the "Event-ID" header.
+type BrokerSink struct {
+	Broker Broker
+	// Subject overrides the subject of an event when not nil.
+	Subject func(event Event) string
+}
+
+func (s BrokerSink) Send(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	subject := event.Type
+	if s.Subject != nil {
+		subject = s.Subject(event)
+	}
+	return s.Broker.Publish(ctx, subject, data, map[string]string{"Event-ID": event.ID})
+}
+
+// DefaultSink return the sink configured by "outbox.sink": "log" or "webhook",
+// posting to "outbox.webhook.url" with a timeout of "outbox.webhook.timeout" seconds.
+// Broker sinks need a client and are built by the application.
+func DefaultSink() (Sink, error) {
+	switch name := viper.GetString("outbox.sink"); name {
+	case "", "log":
+		return LogSink{}, nil
+	case "webhook":
+		url := viper.GetString("outbox.webhook.url")
+		if url == "" {
+			return nil, fmt.Errorf("outbox.webhook.url is required by the webhook sink")
+		}
+		timeout := time.Duration(viper.GetInt("outbox.webhook.timeout")) * time.Second
+		return WebhookSink{URL: url, Client: &http.Client{Timeout: timeout}}, nil
+	default:
+		return nil, fmt.Errorf("outbox sink %q not supported", name)
+	}
+}
@@ -2,16 +2,26 @@ package validation
 
 import (
 	"fmt"
-	"github.com/alpakih/go-api/pkg/database"
-	"github.com/labstack/gommon/log"
+	"github.com/google/uuid"
+	"github.com/nyaruka/phonenumbers"
 	"gopkg.in/go-playground/validator.v9"
 	"gopkg.in/guregu/null.v4"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
+func nullStringValidator(field reflect.Value) interface{} {
+	if valuer, ok := field.Interface().(null.String); ok {
+		if valuer.Valid {
+			return valuer.String
+		}
+	}
+	return nil
+}
+
 func nullFloatValidator(field reflect.Value) interface{} {
 	if valuer, ok := field.Interface().(null.Float); ok {
 		if valuer.Valid {
@@ -39,42 +49,79 @@ func nullTimeValidator(field reflect.Value) interface{} {
 	return nil
 }
 
+var (
+	digitRegex    = regexp.MustCompile(`^[0-9]*$`)
+	dateOnlyRegex = regexp.MustCompile(`^\d{4}-(0[1-9]|1[012])-(0[1-9]|[12][0-9]|3[01])$`)
+	slugRegex     = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+)
+
 func validateOnlyNumber(fl validator.FieldLevel) bool {
 	if fl.Field().String() != "" {
-		regex := regexp.MustCompile(`^[0-9]*$`)
-		return regex.MatchString(fl.Field().String())
+		return digitRegex.MatchString(fl.Field().String())
 	}
 	return true
 }
 
 func validateDateOnly(fl validator.FieldLevel) bool {
 	if fl.Field().String() != "" {
-		regex := regexp.MustCompile(`^\d{4}-(0[1-9]|1[012])-(0[1-9]|[12][0-9]|3[01])$`)
-		return regex.MatchString(fl.Field().String())
+		return dateOnlyRegex.MatchString(fl.Field().String())
 	}
 	return true
 }
 
-func validateUnique(fl validator.FieldLevel) bool {
-	param := strings.Split(fl.Param(), `:`)
-	paramField := param[0]
-	paramTable := param[1]
+// validateDatetime check a date and time in the layout of the parameter, with the
+// reference time of the time package: datetime=2006-01-02 15:04.
+func validateDatetime(fl validator.FieldLevel) bool {
+	if fl.Field().String() != "" {
+		_, err := time.Parse(fl.Param(), fl.Field().String())
+		return err == nil
+	}
+	return true
+}
 
-	if paramField == `` {
-		return true
+// validateTimezone check an IANA time zone name, such as Asia/Jakarta.
+func validateTimezone(fl validator.FieldLevel) bool {
+	if fl.Field().String() != "" {
+		// LoadLocation also accepts "Local", which is not a zone of the clients
+		location, err := time.LoadLocation(fl.Field().String())
+		return err == nil && location.String() != "Local"
 	}
+	return true
+}
 
-	count := int64(0)
+func validateUUIDOrEmpty(fl validator.FieldLevel) bool {
+	if fl.Field().String() != "" {
+		_, err := uuid.Parse(fl.Field().String())
+		return err == nil && len(fl.Field().String()) == 36
+	}
+	return true
+}
 
-	if err := database.Conn().Table(paramTable).Where(paramField+"= ?", fl.Field().String()).
-		Count(&count).Error; err != nil {
-		log.Fatal(err)
+// validateSlug check a lower case slug, words of letters and digits joined by dashes.
+func validateSlug(fl validator.FieldLevel) bool {
+	if fl.Field().String() != "" {
+		return slugRegex.MatchString(fl.Field().String())
+	}
+	return true
+}
 
+// validatePhoneNumber check a phone number in the E.164 format, +6281234567890, or in
+// the national format of the region of the parameter: phone_number=ID accepts
+// 081234567890 too.
+func validatePhoneNumber(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true
 	}
-	if count > 0 {
+	region := strings.ToUpper(fl.Param())
+	if region == "" && !strings.HasPrefix(value, "+") {
 		return false
 	}
-	return true
+	number, err := phonenumbers.Parse(value, region)
+	if err != nil {
+		return false
+	}
+	return phonenumbers.IsValidNumber(number)
 }
 
 //ValidateCustom -- ValidateCustom
@@ -96,39 +143,6 @@ func validateEnum(field validator.FieldLevel) bool {
 	return true
 }
 
-func validateUpdateUnique(fl validator.FieldLevel) bool {
-	param := strings.Split(fl.Param(), `:`)
-	paramFieldValue := param[0]
-	paramTable := param[1]
-	paramField := param[2]
-	paramFieldCond := param[3]
-
-	if paramFieldValue == `` {
-		return true
-	}
-
-	// param field reflect.Value.
-	var paramReflectValue reflect.Value
-
-	if fl.Parent().Kind() == reflect.Ptr {
-		paramReflectValue = fl.Parent().Elem().FieldByName(paramFieldValue)
-	} else {
-		paramReflectValue = fl.Parent().FieldByName(paramFieldValue)
-	}
-
-	count := int64(0)
-
-	if err := database.Conn().Table(paramTable).Where(paramField+" =?", fl.Field().String()).Where(paramFieldCond+" <> ?", paramReflectValue.String()).
-		Count(&count).Error; err != nil {
-		log.Fatal(err)
-
-	}
-	if count > 0 {
-		return false
-	}
-	return true
-}
-
 func validateRequireIfAnotherField(fl validator.FieldLevel) bool {
 	param := strings.Split(fl.Param(), `:`)
 	paramField := param[0]
@@ -0,0 +1,138 @@
+package validation_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+// failedTags return the tags failed by the fields of a validation.
+func failedTags(t *testing.T, err error) map[string]string {
+	tags := map[string]string{}
+	if err == nil {
+		return tags
+	}
+	var errs validator.ValidationErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	for _, fieldErr := range errs {
+		tags[fieldErr.Field()] = fieldErr.Tag()
+	}
+	return tags
+}
+
+type contact struct {
+	Phone      string      `validate:"phone_number"`
+	LocalPhone string      `validate:"phone_number=ID"`
+	StartsAt   string      `validate:"datetime=2006-01-02 15:04"`
+	Timezone   string      `validate:"timezone"`
+	Reference  string      `validate:"uuid_or_empty"`
+	Slug       string      `validate:"slug"`
+	Nickname   null.String `validate:"omitempty,max=5"`
+	Note       null.String `validate:"required"`
+}
+
+func TestFormatRules(t *testing.T) {
+	v := validation.NewValidator()
+	valid := contact{
+		Phone:      "+6281234567890",
+		LocalPhone: "081234567890",
+		StartsAt:   "2021-08-31 13:45",
+		Timezone:   "Asia/Jakarta",
+		Reference:  "6f1c1c2e-8f3b-4b8e-9a4a-2d6f1b0c9e11",
+		Slug:       "go-api-2",
+		Nickname:   null.StringFrom("ali"),
+		Note:       null.StringFrom("note"),
+	}
+	assert.NoError(t, v.Validate(valid))
+
+	empty := contact{Note: null.StringFrom("note")}
+	assert.NoError(t, v.Validate(empty), "empty values are left to required")
+
+	invalid := contact{
+		Phone:      "081234567890",
+		LocalPhone: "+1 202",
+		StartsAt:   "2021-02-31 13:45",
+		Timezone:   "Local",
+		Reference:  "6f1c1c2e8f3b4b8e9a4a2d6f1b0c9e11",
+		Slug:       "Go API",
+		Nickname:   null.StringFrom("alexander"),
+	}
+	assert.Equal(t, map[string]string{
+		"Phone":      "phone_number",
+		"LocalPhone": "phone_number",
+		"StartsAt":   "datetime",
+		"Timezone":   "timezone",
+		"Reference":  "uuid_or_empty",
+		"Slug":       "slug",
+		"Nickname":   "max",
+		"Note":       "required",
+	}, failedTags(t, v.Validate(invalid)))
+}
+
+type upload struct {
+	Avatar     *multipart.FileHeader `validate:"required,file,file_mime=image/png image/jpeg,file_size=1KB"`
+	Attachment *multipart.FileHeader `validate:"omitempty,file_mime=text/csv application/pdf"`
+}
+
+func multipartFiles(t *testing.T, files map[string][]byte, contentTypes map[string]string) map[string]*multipart.FileHeader {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range files {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="`+name+`"; filename="`+name+`"`)
+		header.Set("Content-Type", contentTypes[name])
+		part, err := writer.CreatePart(header)
+		require.NoError(t, err)
+		_, err = part.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = form.RemoveAll() })
+	headers := map[string]*multipart.FileHeader{}
+	for name, fileHeaders := range form.File {
+		headers[name] = fileHeaders[0]
+	}
+	return headers
+}
+
+func TestFileRules(t *testing.T) {
+	v := validation.NewValidator()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	files := multipartFiles(t, map[string][]byte{
+		"avatar.png":  png,
+		"large.png":   append(png, make([]byte, 2048)...),
+		"script.png":  []byte("<html><script>alert(1)</script></html>"),
+		"report.csv":  []byte("id,name\n1,alice\n"),
+		"invoice.pdf": []byte("%PDF-1.4\n"),
+	}, map[string]string{
+		"avatar.png":  "image/png",
+		"large.png":   "image/png",
+		"script.png":  "image/png",
+		"report.csv":  "text/csv",
+		"invoice.pdf": "text/csv",
+	})
+
+	assert.NoError(t, v.Validate(upload{Avatar: files["avatar.png"], Attachment: files["report.csv"]}))
+	assert.NoError(t, v.Validate(upload{Avatar: files["avatar.png"], Attachment: files["invoice.pdf"]}))
+	assert.Equal(t, map[string]string{"Avatar": "required"}, failedTags(t, v.Validate(upload{})))
+	assert.Equal(t, map[string]string{"Avatar": "file_size"}, failedTags(t, v.Validate(upload{Avatar: files["large.png"]})))
+	assert.Equal(t, map[string]string{"Avatar": "file_mime"}, failedTags(t, v.Validate(upload{Avatar: files["script.png"]})),
+		"the declared type is not trusted")
+
+	err := v.Validate(upload{Avatar: files["script.png"]})
+	require.Error(t, err)
+	errs := validation.WrapValidationErrors(context.Background(), err.(validator.ValidationErrors))
+	assert.Equal(t, "The avatar must be a file of type: image/png, image/jpeg.", errs[0].Message)
+}
@@ -0,0 +1,406 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/normalize"
+	"github.com/labstack/gommon/log"
+	"gopkg.in/go-playground/validator.v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RuleError error of a rule unable to check a value, such as on a database error.
+// It is returned by Validate instead of the validation errors, and answered with
+// a 500 rather than a 422.
+type RuleError struct {
+	Tag   string
+	Field string
+	Err   error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("validation rule %s of %s: %s", e.Tag, e.Field, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// ruleErrorsContextKey key of the ruleErrors of a validation in its context.
+type ruleErrorsContextKey struct{}
+
+// ruleErrors the first error of the rules of a validation.
+type ruleErrors struct {
+	mu  sync.Mutex
+	err *RuleError
+}
+
+// ruleFailed record the error of a rule unable to check the value of fl, returning
+// true so that the value is not reported as invalid.
+func ruleFailed(ctx context.Context, fl validator.FieldLevel, err error) bool {
+	ruleErr := &RuleError{Tag: fl.GetTag(), Field: fl.FieldName(), Err: err}
+	errs, ok := ctx.Value(ruleErrorsContextKey{}).(*ruleErrors)
+	if !ok {
+		log.Error(ruleErr)
+		return true
+	}
+	errs.mu.Lock()
+	defer errs.mu.Unlock()
+	if errs.err == nil {
+		errs.err = ruleErr
+	}
+	return true
+}
+
+var (
+	tablesMu sync.RWMutex
+	// tables the tables and columns the database rules may query.
+	tables = map[string]map[string]bool{}
+)
+
+// AllowTable allow the database rules, such as unique, to query the given columns
+// of a table. The names of the validate tags are never concatenated into SQL: the
+// rules fail with a RuleError on the tables and columns not allowed.
+//
+//  validation.AllowTable("users", "id", "username_canonical", "email_index")
+func AllowTable(table string, columns ...string) {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+	if tables[table] == nil {
+		tables[table] = map[string]bool{}
+	}
+	for _, column := range columns {
+		tables[table][column] = true
+	}
+}
+
+// allowed check the given columns of a table are allowed by AllowTable.
+func allowed(table string, columns ...string) error {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+	allowedColumns, ok := tables[table]
+	if !ok {
+		return fmt.Errorf("table %q not allowed, see validation.AllowTable", table)
+	}
+	for _, column := range columns {
+		if !allowedColumns[column] {
+			return fmt.Errorf("column %q of table %q not allowed, see validation.AllowTable", column, table)
+		}
+	}
+	return nil
+}
+
+// tableQuery return a query on the given table, allowed by AllowTable, in the
+// transaction or tenant of ctx.
+func tableQuery(ctx context.Context, table string) (*gorm.DB, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	return database.FromContext(ctx, db).Table(table), nil
+}
+
+var (
+	scopesMu sync.RWMutex
+	// scopes the named conditions the exists rules may add to their queries.
+	scopes = map[string]func(ctx context.Context, db *gorm.DB) (*gorm.DB, error){
+		// rows not soft deleted
+		"not_deleted": func(ctx context.Context, db *gorm.DB) (*gorm.DB, error) {
+			return db.Where(clause.Eq{Column: clause.Column{Name: "deleted_at"}, Value: nil}), nil
+		},
+		// rows of the tenant of the context, in tables shared by tenants
+		"tenant": func(ctx context.Context, db *gorm.DB) (*gorm.DB, error) {
+			tenant, ok := database.TenantFromContext(ctx)
+			if !ok {
+				return nil, errors.New("no tenant in context")
+			}
+			return db.Where(clause.Eq{Column: clause.Column{Name: "tenant_id"}, Value: tenant.ID}), nil
+		},
+	}
+)
+
+// RegisterScope register a named condition the exists rules may add to their
+// queries, given after the column:
+//
+//  validation.RegisterScope("active", func(ctx context.Context, db *gorm.DB) (*gorm.DB, error) {
+//      return db.Where("active = ?", true), nil
+//  })
+//  validate:"exists=categories:id:active:not_deleted"
+func RegisterScope(name string, scope func(ctx context.Context, db *gorm.DB) (*gorm.DB, error)) {
+	scopesMu.Lock()
+	defer scopesMu.Unlock()
+	scopes[name] = scope
+}
+
+// applyScopes add the named scopes to a query.
+func applyScopes(ctx context.Context, db *gorm.DB, names []string) (*gorm.DB, error) {
+	scopesMu.RLock()
+	defer scopesMu.RUnlock()
+	for _, name := range names {
+		scope, ok := scopes[name]
+		if !ok {
+			return nil, fmt.Errorf("scope %q not registered, see validation.RegisterScope", name)
+		}
+		var err error
+		if db, err = scope(ctx, db); err != nil {
+			return nil, fmt.Errorf("scope %s: %w", name, err)
+		}
+	}
+	return db, nil
+}
+
+// normalizers maps the optional normalizer segment of the unique and
+// unique_update rules to the function applied to the value before comparing.
+//
+//  validate:"unique=username_canonical:users:username"
+//  validate:"unique=email_index:users:email_index"
+var normalizers = map[string]func(string) (string, error){
+	"username": func(value string) (string, error) {
+		return normalize.Username(value), nil
+	},
+	// blind index of an encrypted email, see database.BlindIndex
+	"email_index": func(value string) (string, error) {
+		return database.BlindIndex(normalize.Email(value))
+	},
+}
+
+// validateNormalizedMax check the value is at most max characters long once
+// normalized, as normalizing may lengthen it ("ﬃ" becomes "ffi"). It panics on an
+// invalid parameter, as the validator rules do, and fails with a RuleError when the
+// value cannot be normalized, such as without a blind index key:
+//
+//  validate:"normalized_max=username:50"
+func validateNormalizedMax(ctx context.Context, fl validator.FieldLevel) bool {
+	param := strings.Split(fl.Param(), ":")
+	if len(param) != 2 {
+		panic(fmt.Sprintf("normalized_max: invalid parameter %q", fl.Param()))
+	}
+	normalizer, ok := normalizers[param[0]]
+	if !ok {
+		panic(fmt.Sprintf("normalized_max: unknown normalizer %q", param[0]))
+	}
+	value, err := normalizer(fl.Field().String())
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	return utf8.RuneCountInString(value) <= int(asInt(param[1]))
+}
+
+func normalizeParam(value string, param []string, index int) (string, error) {
+	if len(param) > index {
+		if normalizer, ok := normalizers[param[index]]; ok {
+			return normalizer(value)
+		}
+	}
+	return value, nil
+}
+
+// validateUnique check no row of a table has the value in a column:
+//
+//  validate:"unique=column:table[:normalizer]"
+func validateUnique(ctx context.Context, fl validator.FieldLevel) bool {
+	param := strings.Split(fl.Param(), `:`)
+	if param[0] == `` {
+		return true
+	}
+	if len(param) < 2 {
+		return ruleFailed(ctx, fl, fmt.Errorf("invalid parameter %q", fl.Param()))
+	}
+	paramField := param[0]
+	paramTable := param[1]
+	if err := allowed(paramTable, paramField); err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+
+	value, err := normalizeParam(fl.Field().String(), param, 2)
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+	db, err := tableQuery(ctx, paramTable)
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	count := int64(0)
+	if err := db.Where(clause.Eq{Column: clause.Column{Name: paramField}, Value: value}).Count(&count).Error; err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	return count == 0
+}
+
+// validateUpdateUnique check no row of a table other than the one being updated,
+// identified by a field of the struct, has the value in a column:
+//
+//  validate:"unique_update=IDField:table:column:idColumn[:normalizer]"
+func validateUpdateUnique(ctx context.Context, fl validator.FieldLevel) bool {
+	param := strings.Split(fl.Param(), `:`)
+	if param[0] == `` {
+		return true
+	}
+	if len(param) < 4 {
+		return ruleFailed(ctx, fl, fmt.Errorf("invalid parameter %q", fl.Param()))
+	}
+	paramFieldValue := param[0]
+	paramTable := param[1]
+	paramField := param[2]
+	paramFieldCond := param[3]
+	if err := allowed(paramTable, paramField, paramFieldCond); err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+
+	// param field reflect.Value.
+	var paramReflectValue reflect.Value
+
+	if fl.Parent().Kind() == reflect.Ptr {
+		paramReflectValue = fl.Parent().Elem().FieldByName(paramFieldValue)
+	} else {
+		paramReflectValue = fl.Parent().FieldByName(paramFieldValue)
+	}
+	if !paramReflectValue.IsValid() {
+		return ruleFailed(ctx, fl, fmt.Errorf("no field %s", paramFieldValue))
+	}
+
+	value, err := normalizeParam(fl.Field().String(), param, 4)
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+	db, err := tableQuery(ctx, paramTable)
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	count := int64(0)
+	if err := db.Where(clause.Eq{Column: clause.Column{Name: paramField}, Value: value}).
+		Where(clause.Neq{Column: clause.Column{Name: paramFieldCond}, Value: fmt.Sprintf("%v", paramReflectValue.Interface())}).
+		Count(&count).Error; err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	return count == 0
+}
+
+// validateExists check a row of a table has the value in a column, such as the
+// row referenced by a foreign key, among the rows matching the optional scopes:
+//
+//  validate:"exists=table:column[:scope...]"
+func validateExists(ctx context.Context, fl validator.FieldLevel) bool {
+	param := strings.Split(fl.Param(), `:`)
+	if len(param) < 2 {
+		return ruleFailed(ctx, fl, fmt.Errorf("invalid parameter %q", fl.Param()))
+	}
+	return exists(ctx, fl, param[0], param[1], param[2:], func(db *gorm.DB) *gorm.DB { return db })
+}
+
+// validateExistsExcept check a row of a table other than the one being updated,
+// identified by a field of the struct, has the value in a column, such as the
+// parent of a category which may not be the category itself:
+//
+//  validate:"exists_except=IDField:table:column:idColumn[:scope...]"
+func validateExistsExcept(ctx context.Context, fl validator.FieldLevel) bool {
+	param := strings.Split(fl.Param(), `:`)
+	if len(param) < 4 {
+		return ruleFailed(ctx, fl, fmt.Errorf("invalid parameter %q", fl.Param()))
+	}
+	paramFieldValue := param[0]
+	paramFieldCond := param[3]
+	if err := allowed(param[1], paramFieldCond); err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+
+	var paramReflectValue reflect.Value
+	if fl.Parent().Kind() == reflect.Ptr {
+		paramReflectValue = fl.Parent().Elem().FieldByName(paramFieldValue)
+	} else {
+		paramReflectValue = fl.Parent().FieldByName(paramFieldValue)
+	}
+	if !paramReflectValue.IsValid() {
+		return ruleFailed(ctx, fl, fmt.Errorf("no field %s", paramFieldValue))
+	}
+
+	return exists(ctx, fl, param[1], param[2], param[4:], func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Neq{Column: clause.Column{Name: paramFieldCond}, Value: paramReflectValue.Interface()})
+	})
+}
+
+// exists check the value of fl is in a column of a table, among the rows of the scopes.
+func exists(ctx context.Context, fl validator.FieldLevel, table, column string, scopeNames []string, where func(db *gorm.DB) *gorm.DB) bool {
+	if err := allowed(table, column); err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+	db, err := tableQuery(ctx, table)
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	if db, err = applyScopes(ctx, db, scopeNames); err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	count := int64(0)
+	if err := where(db).Where(clause.Eq{Column: clause.Column{Name: column}, Value: fl.Field().Interface()}).
+		Count(&count).Error; err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	return count > 0
+}
+
+// validateExistsAll check every value of a slice is in a column of a table, among
+// the rows matching the optional scopes, with a single IN query:
+//
+//  validate:"exists_all=table:column[:scope...]"
+func validateExistsAll(ctx context.Context, fl validator.FieldLevel) bool {
+	param := strings.Split(fl.Param(), `:`)
+	if len(param) < 2 {
+		return ruleFailed(ctx, fl, fmt.Errorf("invalid parameter %q", fl.Param()))
+	}
+	table, column := param[0], param[1]
+	if err := allowed(table, column); err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+
+	field := fl.Field()
+	if field.Kind() != reflect.Slice && field.Kind() != reflect.Array {
+		return ruleFailed(ctx, fl, fmt.Errorf("%s is not a slice", field.Type()))
+	}
+	// the distinct values, compared with the number of distinct values found
+	seen := map[interface{}]bool{}
+	values := make([]interface{}, 0, field.Len())
+	for i := 0; i < field.Len(); i++ {
+		value := field.Index(i).Interface()
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return true
+	}
+
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+	db, err := tableQuery(ctx, table)
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	if db, err = applyScopes(ctx, db, param[2:]); err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	count := int64(0)
+	if err := db.Where(clause.IN{Column: clause.Column{Name: column}, Values: values}).
+		Distinct(column).Count(&count).Error; err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	return count == int64(len(values))
+}
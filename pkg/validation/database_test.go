@@ -0,0 +1,169 @@
+package validation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alpakih/go-api/pkg/database"
+	"github.com/alpakih/go-api/pkg/database/databasetest"
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type member struct {
+	ID    uint
+	Email string
+}
+
+type storeMember struct {
+	Email string `validate:"unique=email:members"`
+}
+
+type updateMember struct {
+	ID    uint
+	Email string `validate:"unique_update=ID:members:email:id"`
+}
+
+type storeSecret struct {
+	Value string `validate:"unique=value:secrets"`
+}
+
+type storeMissing struct {
+	Name string `validate:"unique=name:missing_members"`
+}
+
+func TestDatabaseRules(t *testing.T) {
+	validation.AllowTable("members", "id", "email")
+	validation.AllowTable("missing_members", "name")
+	db := databasetest.New(t, member{})
+	require.NoError(t, db.Create(&member{Email: "taken@example.com"}).Error)
+	v := validation.NewValidator()
+	ctx := context.Background()
+
+	assert.NoError(t, v.ValidateCtx(ctx, storeMember{Email: "free@example.com"}))
+	var validationErrors validator.ValidationErrors
+	assert.True(t, errors.As(v.ValidateCtx(ctx, storeMember{Email: "taken@example.com"}), &validationErrors))
+	assert.NoError(t, v.ValidateCtx(ctx, updateMember{ID: 1, Email: "taken@example.com"}))
+	assert.Error(t, v.ValidateCtx(ctx, updateMember{ID: 2, Email: "taken@example.com"}))
+
+	err := database.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := database.FromContext(ctx, db).Create(&member{Email: "pending@example.com"}).Error; err != nil {
+			return err
+		}
+		assert.Error(t, v.ValidateCtx(ctx, storeMember{Email: "pending@example.com"}), "the rules query the transaction of the context")
+		return errors.New("rolled back")
+	})
+	assert.EqualError(t, err, "rolled back")
+
+	var ruleError *validation.RuleError
+	require.True(t, errors.As(v.ValidateCtx(ctx, storeSecret{Value: "x"}), &ruleError), "tables must be allowed")
+	assert.Equal(t, "unique", ruleError.Tag)
+	assert.Contains(t, ruleError.Error(), `table "secrets" not allowed`)
+
+	assert.True(t, errors.As(v.ValidateCtx(ctx, storeMissing{Name: "x"}), &ruleError), "database errors do not exit")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, v.ValidateCtx(cancelled, storeMember{Email: "free@example.com"}), context.Canceled)
+}
+
+type category struct {
+	ID        uint
+	ParentID  *uint
+	DeletedAt *time.Time
+}
+
+type storeCategory struct {
+	ParentID uint `validate:"omitempty,exists=categories:id:not_deleted"`
+}
+
+type updateCategory struct {
+	ID       uint
+	ParentID uint `validate:"omitempty,exists_except=ID:categories:id:id"`
+}
+
+type tagCategories struct {
+	Categories []uint `validate:"exists_all=categories:id:not_deleted"`
+}
+
+type scopedCategory struct {
+	ParentID uint `validate:"exists=categories:id:unknown"`
+}
+
+func TestExistsRules(t *testing.T) {
+	validation.AllowTable("categories", "id")
+	db := databasetest.New(t, category{})
+	deletedAt := time.Now()
+	require.NoError(t, db.Create(&[]category{{ID: 1}, {ID: 2}, {ID: 3, DeletedAt: &deletedAt}}).Error)
+	v := validation.NewValidator()
+	ctx := context.Background()
+
+	assert.NoError(t, v.ValidateCtx(ctx, storeCategory{ParentID: 1}))
+	assert.Error(t, v.ValidateCtx(ctx, storeCategory{ParentID: 3}), "soft deleted rows are out of the scope")
+	assert.Error(t, v.ValidateCtx(ctx, storeCategory{ParentID: 42}))
+
+	assert.NoError(t, v.ValidateCtx(ctx, updateCategory{ID: 2, ParentID: 1}))
+	assert.Error(t, v.ValidateCtx(ctx, updateCategory{ID: 1, ParentID: 1}), "a category is not its own parent")
+
+	assert.NoError(t, v.ValidateCtx(ctx, tagCategories{Categories: []uint{1, 2, 2}}))
+	assert.NoError(t, v.ValidateCtx(ctx, tagCategories{}))
+	err := v.ValidateCtx(ctx, tagCategories{Categories: []uint{1, 3, 42}})
+	var validationErrors validator.ValidationErrors
+	require.True(t, errors.As(err, &validationErrors))
+	assert.Equal(t, "The categories contains invalid values.",
+		validation.WrapValidationErrors(ctx, validationErrors)[0].Message)
+
+	var ruleError *validation.RuleError
+	assert.True(t, errors.As(v.ValidateCtx(ctx, scopedCategory{ParentID: 1}), &ruleError), "scopes must be registered")
+}
+
+func TestExistsAllSingleQuery(t *testing.T) {
+	validation.AllowTable("categories", "id")
+	db := databasetest.New(t, category{})
+	require.NoError(t, db.Create(&[]category{{ID: 1}, {ID: 2}}).Error)
+
+	queryLogger := &database.Logger{LogLevel: logger.Warn}
+	require.NoError(t, db.Use(queryLogger))
+	database.SetConnection(db.Session(&gorm.Session{Logger: queryLogger}))
+	ctx, stats := database.WithQueryStats(context.Background(), "validate")
+	require.NoError(t, validation.NewValidator().ValidateCtx(ctx, tagCategories{Categories: []uint{1, 2}}))
+	assert.Equal(t, 1, stats.Count())
+}
+
+type account struct {
+	Username string `json:"username" validate:"max=5,normalized_max=username:5"`
+}
+
+func TestNormalizedMax(t *testing.T) {
+	v := validation.NewValidator()
+	assert.NoError(t, v.Validate(account{Username: "Alice"}))
+
+	// 4 characters, 6 once normalized
+	err := v.Validate(account{Username: "aﬃbc"})
+	assert.Equal(t, map[string]string{"username": "normalized_max"}, failedTags(t, err))
+	errs := validation.WrapValidationErrors(context.Background(), err.(validator.ValidationErrors))
+	assert.Equal(t, "The username may not be greater than 5 characters.", errs[0].Message)
+}
+
+type indexedAccount struct {
+	Email string `json:"email" validate:"normalized_max=email_index:64"`
+}
+
+func TestNormalizedMaxRuleError(t *testing.T) {
+	keyring, err := database.NewKeyring(1, map[int][]byte{1: make([]byte, 32)}, nil)
+	require.NoError(t, err)
+	database.SetKeyring(keyring)
+	t.Cleanup(database.Reset)
+
+	var ruleError *validation.RuleError
+	err = validation.NewValidator().ValidateCtx(context.Background(), indexedAccount{Email: "alice@example.com"})
+	require.True(t, errors.As(err, &ruleError), "normalizer errors do not panic")
+	assert.Equal(t, "normalized_max", ruleError.Tag)
+	assert.ErrorIs(t, err, database.ErrNoBlindIndexKey)
+}
@@ -1,14 +1,17 @@
 package validation
 
 import (
+	"context"
 	"fmt"
-	"github.com/iancoleman/strcase"
 	"gopkg.in/go-playground/validator.v9"
 	"strings"
 )
 
 type ErrorValidation struct {
+	// Field JSON name of the field, and Path its JSON Pointer from the validated
+	// struct, such as /items/3/email.
 	Field     string `json:"field,omitempty"`
+	Path      string `json:"path"`
 	ActualTag string `json:"tag,omitempty"`
 	Namespace string `json:"namespace,omitempty"`
 	Kind      string `json:"kind,omitempty"`
@@ -18,75 +21,88 @@ type ErrorValidation struct {
 	Message   string `json:"message"`
 }
 
-func WrapValidationErrors(errs validator.ValidationErrors) []ErrorValidation {
+// WrapValidationErrors wrap validation errors for the response, with their messages
+// in the language of ctx. The values of passwords are left out.
+func WrapValidationErrors(ctx context.Context, errs validator.ValidationErrors) []ErrorValidation {
 	validationErrors := make([]ErrorValidation, 0, len(errs))
 	for _, validationErr := range errs {
+		value := fmt.Sprintf("%v", validationErr.Value())
+		if isPasswordError(validationErr) {
+			value = ""
+		}
 		validationErrors = append(validationErrors, ErrorValidation{
+			Field:     validationErr.Field(),
+			Path:      JSONPointer(validationErr.Namespace()),
 			ActualTag: validationErr.ActualTag(),
 			Namespace: validationErr.Namespace(),
 			Kind:      validationErr.Kind().String(),
 			Type:      validationErr.Type().String(),
-			Value:     fmt.Sprintf("%v", validationErr.Value()),
+			Value:     value,
 			Param:     validationErr.Param(),
-			Message:   FormatMessage(validationErr),
+			Message:   FormatMessage(ctx, validationErr),
 		})
 	}
 
 	return validationErrors
 }
 
-func FormatMessage(err validator.FieldError) string {
-	field := err.Field()
-	param := err.Param()
-
-	message := fmt.Sprintf("Field validation for '%s' failed on the '%s'", strcase.ToSnake(err.Field()), err.Tag())
+// GroupValidationErrors group validation errors by their Path, for forms to bind them
+// to their inputs.
+func GroupValidationErrors(errs []ErrorValidation) map[string][]ErrorValidation {
+	groups := make(map[string][]ErrorValidation, len(errs))
+	for _, err := range errs {
+		groups[err.Path] = append(groups[err.Path], err)
+	}
+	return groups
+}
 
-	switch err.Tag() {
-	case "required":
-		message = fmt.Sprintf("The %s field is required.", field)
-	case "number":
-		message = fmt.Sprintf("The %s must be a number.", field)
-	case "email":
-		message = fmt.Sprintf("The %s must be a valid email address.", field)
-	case "gt":
-		message = fmt.Sprintf("The %s must be greater than %s.", field, param)
-	case "gte":
-		message = fmt.Sprintf("The %s must be greater than or equal %s.", field, param)
-	case "lt":
-		message = fmt.Sprintf("The %s must be less than %s.", field, param)
-	case "lte":
-		message = fmt.Sprintf("The %s must be less than or equal %s.", field, param)
-	case "phone_number":
-		message = fmt.Sprintf("The %s must be a valid phone number.", field)
-	case "min":
-		message = fmt.Sprintf("The %s must be at least %s", field, param)
-	case "max":
-		message = fmt.Sprintf("The %s may not be greater than %s.", field, param)
-	case "len":
-		message = fmt.Sprintf("The %s must be a length %s.", field, param)
-	case "eq":
-		message = fmt.Sprintf("The %s must be a equals %s.", field, param)
-	case "date_only":
-		message = fmt.Sprintf("The %s must be valid format %s.", field, "yyyy-mm-dd")
-	case "unique":
-		message = fmt.Sprintf("The %s %s is already exist.", strcase.ToSnake(field), err.Value())
-	case "unique_update":
-		message = fmt.Sprintf("The %s %s is already exist.", strcase.ToSnake(field), err.Value())
-	case "digit":
-		message = fmt.Sprintf("The %s must be a digit number of string.", strcase.ToSnake(field))
-	case "enum":
-		// first, clean/remove the comma
-		cleaned := strings.Replace(param, "_", " ", -1)
+// JSONPointer return the JSON Pointer (RFC 6901) of the namespace of a validation
+// error, StoreRequest.items[3].email giving /items/3/email.
+func JSONPointer(namespace string) string {
+	// the namespace starts with the name of the validated struct
+	start := strings.IndexAny(namespace, ".[")
+	if start < 0 {
+		return ""
+	}
 
-		// convert 'clened' comma separated string to slice
-		strSlice := strings.Fields(cleaned)
-		message = fmt.Sprintf("The %s is must only value %s instead.", strcase.ToSnake(field), strings.Join(strSlice, ","))
-	case "rfe":
-		params := strings.Split(param, `:`)
-		paramField := params[0]
-		paramValue := params[1]
-		message = fmt.Sprintf("The %s is required if %s = %s.", strcase.ToSnake(field), strcase.ToSnake(paramField), paramValue)
+	var pointer, token strings.Builder
+	flush := func() {
+		if token.Len() > 0 {
+			pointer.WriteByte('/')
+			pointer.WriteString(jsonPointerEscaper.Replace(token.String()))
+			token.Reset()
+		}
+	}
+	inIndex := false
+	for _, r := range namespace[start:] {
+		switch {
+		case r == '[' && !inIndex:
+			flush()
+			inIndex = true
+		case r == ']' && inIndex:
+			flush()
+			inIndex = false
+		case r == '.' && !inIndex:
+			flush()
+		default:
+			token.WriteRune(r)
+		}
 	}
+	flush()
+	return pointer.String()
+}
+
+// jsonPointerEscaper escape the reference tokens of a JSON Pointer.
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// FormatMessage return the message of a validation error in the language of ctx.
+func FormatMessage(ctx context.Context, err validator.FieldError) string {
+	return Translate(Language(ctx), err)
+}
 
-	return message
+// isPasswordError tell whether a validation error is about a password, failing a
+// password tag or on a field named after passwords.
+func isPasswordError(err validator.FieldError) bool {
+	return err.Tag() == "password" || strings.HasPrefix(err.ActualTag(), "password_") ||
+		strings.Contains(strings.ToLower(err.StructField()), "password")
 }
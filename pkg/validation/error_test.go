@@ -0,0 +1,67 @@
+package validation_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderItem struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Email     string `json:"email" validate:"omitempty,email"`
+}
+
+type order struct {
+	CustomerName string            `json:"customerName" validate:"required"`
+	Items        []orderItem       `json:"items" validate:"required,dive"`
+	Notes        map[string]string `json:"notes" validate:"dive,max=3"`
+	Internal     string            `json:"-" validate:"required"`
+}
+
+func TestErrorPaths(t *testing.T) {
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	request := order{
+		Items: []orderItem{{ProductID: "1"}, {ProductID: "2"}, {}, {ProductID: "4", Email: "nope"}},
+		Notes: map[string]string{"a/b": "long"},
+	}
+	ctx := e.NewContext(httptest.NewRequest(http.MethodPost, "/orders", nil), httptest.NewRecorder())
+	err := validation.ValidateRequest(ctx, request)
+	require.Error(t, err)
+
+	require.NoError(t, validation.ErrorResponse(ctx, err))
+	recorder := ctx.Response().Writer.(*httptest.ResponseRecorder)
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+
+	var response struct {
+		Errors map[string][]validation.ErrorValidation `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	paths := map[string]string{}
+	for path, errs := range response.Errors {
+		require.Len(t, errs, 1)
+		assert.Equal(t, path, errs[0].Path)
+		paths[path] = errs[0].Field
+	}
+	assert.Equal(t, map[string]string{
+		"/customerName":       "customerName",
+		"/items/2/product_id": "product_id",
+		"/items/3/email":      "email",
+		"/notes/a~1b":         "notes[a/b]",
+		"/Internal":           "Internal",
+	}, paths)
+	assert.Equal(t, "The customer_name field is required.", response.Errors["/customerName"][0].Message)
+}
+
+func TestJSONPointer(t *testing.T) {
+	assert.Equal(t, "/items/3/email", validation.JSONPointer("order.items[3].email"))
+	assert.Equal(t, "/notes/a.b~0c", validation.JSONPointer("order.notes[a.b~c]"))
+	assert.Equal(t, "/0", validation.JSONPointer("orders[0]"))
+	assert.Equal(t, "", validation.JSONPointer("order"))
+}
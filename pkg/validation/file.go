@@ -0,0 +1,150 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// uploadedFile the value validated for a multipart.FileHeader field: the validator
+// only runs the tags of struct fields whose custom type is not a struct.
+type uploadedFile []*multipart.FileHeader
+
+// sniffLength number of bytes read to detect the type of a file.
+const sniffLength = 512
+
+// sizeUnits units of the file_size parameter, in bytes.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+func fileHeaderValidator(field reflect.Value) interface{} {
+	if field.CanAddr() {
+		if header, ok := field.Addr().Interface().(*multipart.FileHeader); ok {
+			return uploadedFile{header}
+		}
+	}
+	if header, ok := field.Interface().(multipart.FileHeader); ok {
+		return uploadedFile{&header}
+	}
+	return nil
+}
+
+// fieldFile return the uploaded file of a *multipart.FileHeader field.
+func fieldFile(fl validator.FieldLevel) (*multipart.FileHeader, bool) {
+	file, ok := fl.Field().Interface().(uploadedFile)
+	if !ok || len(file) == 0 {
+		return nil, false
+	}
+	return file[0], true
+}
+
+// validateFile check a *multipart.FileHeader field is an uploaded file, or a string
+// field the path of an existing file as the file tag of the validator does.
+func validateFile(fl validator.FieldLevel) bool {
+	if _, ok := fieldFile(fl); ok {
+		return true
+	}
+	if fl.Field().Kind() == reflect.String {
+		info, err := os.Stat(fl.Field().String())
+		return err == nil && !info.IsDir()
+	}
+	return false
+}
+
+// validateFileMime check the type of an uploaded file is one of those of the parameter,
+// such as file_mime=image/png image/jpeg or file_mime=image/*. The type is detected
+// from the content of the file, the type declared by the client being only trusted
+// for the contents detected as plain text or binary data, such as CSV files.
+func validateFileMime(ctx context.Context, fl validator.FieldLevel) bool {
+	header, ok := fieldFile(fl)
+	if !ok {
+		return true
+	}
+	detected, err := detectContentType(header)
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	types := []string{detected}
+	if detected == "text/plain" || detected == "application/octet-stream" {
+		if declared, _, err := mime.ParseMediaType(header.Header.Get("Content-Type")); err == nil {
+			types = append(types, declared)
+		}
+	}
+	for _, allowed := range strings.Fields(fl.Param()) {
+		for _, contentType := range types {
+			if matchMediaType(allowed, contentType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateFileSize check the size of an uploaded file is at most the parameter, in
+// bytes or with a B, KB, MB or GB unit: file_size=2MB.
+func validateFileSize(fl validator.FieldLevel) bool {
+	header, ok := fieldFile(fl)
+	if !ok {
+		return true
+	}
+	return header.Size <= parseSize(fl.Param())
+}
+
+// detectContentType detect the media type of an uploaded file from its first bytes.
+func detectContentType(header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", fmt.Errorf("open uploaded file %s: %w", header.Filename, err)
+	}
+	defer file.Close()
+
+	content := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, content)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("read uploaded file %s: %w", header.Filename, err)
+	}
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(content[:n]))
+	if err != nil {
+		return "", err
+	}
+	return contentType, nil
+}
+
+// matchMediaType tell whether a media type matches a pattern such as image/png or image/*.
+func matchMediaType(pattern, mediaType string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == mediaType
+}
+
+// parseSize parse a size such as 512KB into bytes, panicking on an invalid size as the
+// validator does on invalid parameters.
+func parseSize(param string) int64 {
+	size := strings.ToUpper(strings.TrimSpace(param))
+	unit := int64(1)
+	for _, sizeUnit := range sizeUnits {
+		if strings.HasSuffix(size, sizeUnit.suffix) {
+			size = strings.TrimSpace(strings.TrimSuffix(size, sizeUnit.suffix))
+			unit = sizeUnit.bytes
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(size, 64)
+	if err != nil || value < 0 {
+		panic(fmt.Sprintf("validation: invalid file size %q", param))
+	}
+	return int64(value * float64(unit))
+}
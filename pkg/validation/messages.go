@@ -0,0 +1,229 @@
+package validation
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/spf13/viper"
+	"golang.org/x/text/language"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// fallbackLanguage language of the messages when "app.defaultLanguage" is not supported.
+const fallbackLanguage = "en-US"
+
+// catalogFiles message catalogs, one JSON file per language named after its BCP 47 tag.
+// Each maps a validation tag, optionally suffixed by the kind of the field (".string",
+// ".number" or ".items"), to a message template with the {field}, {param}, {value}
+// and {tag} placeholders.
+//
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+// languageContextKey key of the language of the validation messages in a context.
+type languageContextKey struct{}
+
+var (
+	catalogs        = map[string]map[string]string{}
+	languages       []string
+	languageMatcher language.Matcher
+
+	// paramFormatters format the parameter of the tags whose raw parameter is not
+	// meant for humans.
+	paramFormatters = map[string]func(param string) string{
+		"enum": func(param string) string {
+			return strings.Join(strings.Fields(strings.Replace(param, "_", " ", -1)), ", ")
+		},
+		"oneof":     joinFields,
+		"file_mime": joinFields,
+		"rfe": func(param string) string {
+			params := strings.SplitN(param, ":", 2)
+			if len(params) < 2 {
+				return param
+			}
+			return strcase.ToSnake(params[0]) + " = " + params[1]
+		},
+		"date_only": func(string) string {
+			return "yyyy-mm-dd"
+		},
+		"password_length": func(string) string {
+			return strconv.Itoa(GetPasswordPolicy().MinLength)
+		},
+		"normalized_max": func(param string) string {
+			return param[strings.LastIndex(param, ":")+1:]
+		},
+		"password_username":    formatFields,
+		"required_with":        formatFields,
+		"required_with_all":    formatFields,
+		"required_without":     formatFields,
+		"required_without_all": formatFields,
+		"eqfield":              formatFields,
+		"nefield":              formatFields,
+		"gtfield":              formatFields,
+		"gtefield":             formatFields,
+		"ltfield":              formatFields,
+		"ltefield":             formatFields,
+	}
+)
+
+func init() {
+	files, err := catalogFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	tags := make([]language.Tag, 0, len(files))
+	for _, file := range files {
+		lang := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+		content, err := catalogFiles.ReadFile("locales/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(content, &catalog); err != nil {
+			panic(fmt.Sprintf("validation catalog %s: %s", file.Name(), err))
+		}
+		catalogs[lang] = catalog
+		languages = append(languages, lang)
+		tags = append(tags, language.MustParse(lang))
+	}
+	languageMatcher = language.NewMatcher(tags)
+}
+
+// joinFields format a parameter listing values separated by spaces.
+func joinFields(param string) string {
+	return strings.Join(strings.Fields(param), ", ")
+}
+
+// formatFields format the field names of a parameter such as "FirstName LastName".
+func formatFields(param string) string {
+	fields := strings.Fields(param)
+	for i, field := range fields {
+		fields[i] = strcase.ToSnake(field)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// Languages return the languages of the validation messages, sorted.
+func Languages() []string {
+	sorted := append([]string(nil), languages...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// DefaultLanguage return "app.defaultLanguage", or en-US when it is not supported.
+func DefaultLanguage() string {
+	if lang := viper.GetString("app.defaultLanguage"); catalogs[lang] != nil {
+		return lang
+	}
+	return fallbackLanguage
+}
+
+// MatchLanguage return the supported language best matching an Accept-Language
+// header, such as "id,en-US;q=0.8", the default language when none does.
+func MatchLanguage(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLanguage()
+	}
+	_, index, confidence := languageMatcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLanguage()
+	}
+	return languages[index]
+}
+
+// WithLanguage return a context whose validation messages are in the given language.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageContextKey{}, lang)
+}
+
+// Language return the language of the validation messages of ctx, the default
+// language unless set by WithLanguage.
+func Language(ctx context.Context) string {
+	if lang, ok := ctx.Value(languageContextKey{}).(string); ok && catalogs[lang] != nil {
+		return lang
+	}
+	return DefaultLanguage()
+}
+
+// Translate return the message of a validation error in the given language, falling
+// back to the default language for the tags missing from its catalog. The errors of
+// an alias, such as password, have the message of the failed tag of the alias.
+func Translate(lang string, err validator.FieldError) string {
+	tags := []string{err.Tag()}
+	if err.ActualTag() != err.Tag() {
+		tags = []string{err.ActualTag(), err.Tag()}
+	}
+	param := err.Param()
+	for _, tag := range tags {
+		if formatter, ok := paramFormatters[tag]; ok {
+			param = formatter(param)
+			break
+		}
+	}
+	replacer := strings.NewReplacer(
+		"{field}", strcase.ToSnake(err.Field()),
+		"{param}", param,
+		"{value}", fmt.Sprintf("%v", err.Value()),
+		"{tag}", err.Tag(),
+	)
+
+	kind := kindSuffix(err.Kind())
+	keys := make([]string, 0, 2*len(tags))
+	for _, tag := range tags {
+		if kind != "" {
+			keys = append(keys, tag+kind)
+		}
+		keys = append(keys, tag)
+	}
+	catalogLanguages := []string{lang, DefaultLanguage()}
+	for _, catalogLanguage := range catalogLanguages {
+		for _, key := range keys {
+			if template, ok := catalogs[catalogLanguage][key]; ok {
+				return replacer.Replace(template)
+			}
+		}
+	}
+	for _, tag := range tags {
+		if template, ok := ruleMessage(tag); ok {
+			return replacer.Replace(template)
+		}
+	}
+	for _, catalogLanguage := range catalogLanguages {
+		if template, ok := catalogs[catalogLanguage]["default"]; ok {
+			return replacer.Replace(template)
+		}
+	}
+	return replacer.Replace(catalogs[fallbackLanguage]["default"])
+}
+
+// ruleMessage return the message template given to RegisterRule for a tag.
+func ruleMessage(tag string) (string, bool) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	template, ok := ruleMessages[tag]
+	return template, ok && template != ""
+}
+
+// kindSuffix return the suffix of the catalog keys specific to the kind of a field.
+func kindSuffix(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return ".string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return ".number"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return ".items"
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package validation_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type signup struct {
+	Username string   `validate:"required,min=3"`
+	Age      int      `validate:"min=18"`
+	Tags     []string `validate:"max=1"`
+	Role     string   `validate:"enum=admin_member"`
+	Website  string   `validate:"omitempty,url"`
+}
+
+func messages(t *testing.T, ctx context.Context, request signup) []string {
+	err := validation.NewValidator().Validate(request)
+	require.Error(t, err)
+	var result []string
+	for _, validationErr := range validation.WrapValidationErrors(ctx, err.(validator.ValidationErrors)) {
+		result = append(result, validationErr.Message)
+	}
+	return result
+}
+
+func TestMessages(t *testing.T) {
+	defer viper.Reset()
+	request := signup{Username: "al", Age: 12, Tags: []string{"a", "b"}, Role: "guest", Website: "nope"}
+
+	assert.Equal(t, []string{
+		"The username must be at least 3 characters.",
+		"The age must be at least 18.",
+		"The tags may not have more than 1 items.",
+		"The role must be one of admin, member.",
+		"The website must be a valid URL.",
+	}, messages(t, context.Background(), request))
+
+	assert.Equal(t, []string{
+		"username minimal 3 karakter.",
+		"age minimal 18.",
+		"tags maksimal berisi 1 item.",
+		"role harus salah satu dari admin, member.",
+		"website harus berupa URL yang valid.",
+	}, messages(t, validation.WithLanguage(context.Background(), "id-ID"), request))
+
+	viper.Set("app.defaultLanguage", "id-ID")
+	assert.Equal(t, []string{"username wajib diisi."}, messages(t, context.Background(), signup{Age: 18, Role: "admin"})[:1])
+}
+
+func TestMatchLanguage(t *testing.T) {
+	defer viper.Reset()
+	assert.Equal(t, "id-ID", validation.MatchLanguage("id"))
+	assert.Equal(t, "id-ID", validation.MatchLanguage("fr-FR, id;q=0.9, en;q=0.8"))
+	assert.Equal(t, "en-US", validation.MatchLanguage("en-GB"))
+	assert.Equal(t, "en-US", validation.MatchLanguage("fr-FR"))
+	assert.Equal(t, "en-US", validation.MatchLanguage(""))
+
+	viper.Set("app.defaultLanguage", "id-ID")
+	assert.Equal(t, "id-ID", validation.MatchLanguage("fr-FR"))
+}
+
+// TestCatalogsComplete checks every language translates every message of the default catalog.
+func TestCatalogsComplete(t *testing.T) {
+	files, err := os.ReadDir("locales")
+	require.NoError(t, err)
+	catalogs := map[string]map[string]string{}
+	for _, file := range files {
+		content, err := os.ReadFile("locales/" + file.Name())
+		require.NoError(t, err)
+		catalog := map[string]string{}
+		require.NoError(t, json.Unmarshal(content, &catalog), file.Name())
+		catalogs[file.Name()] = catalog
+	}
+	require.Contains(t, catalogs, "en-US.json")
+
+	for file, catalog := range catalogs {
+		for key := range catalogs["en-US.json"] {
+			assert.Contains(t, catalog, key, file)
+		}
+		assert.Len(t, catalog, len(catalogs["en-US.json"]), file)
+	}
+}
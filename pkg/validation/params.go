@@ -0,0 +1,239 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/labstack/echo/v4"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// paramSources the tags of the fields bound by BindParams, by order of precedence.
+var paramSources = []string{"param", "query", "header"}
+
+// ParamErrors errors of the parameters of a request: the parameters not converted to
+// the type of their field, and the validation errors of the others. It is answered
+// by ErrorResponse as the validation errors are.
+type ParamErrors []validator.FieldError
+
+func (e ParamErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, fmt.Sprintf("Key: '%s' Error:Field validation for '%s' failed on the '%s' tag",
+			err.Namespace(), err.Field(), err.Tag()))
+	}
+	return strings.Join(messages, "\n")
+}
+
+// conversionError a parameter not converted to the type of its field, reported on
+// the number or boolean tag.
+type conversionError struct {
+	tag             string
+	namespace       string
+	structNamespace string
+	field           string
+	structField     string
+	value           string
+	typ             reflect.Type
+}
+
+func (e *conversionError) Tag() string             { return e.tag }
+func (e *conversionError) ActualTag() string       { return e.tag }
+func (e *conversionError) Namespace() string       { return e.namespace }
+func (e *conversionError) StructNamespace() string { return e.structNamespace }
+func (e *conversionError) Field() string           { return e.field }
+func (e *conversionError) StructField() string     { return e.structField }
+func (e *conversionError) Value() interface{}      { return e.value }
+func (e *conversionError) Param() string           { return "" }
+func (e *conversionError) Kind() reflect.Kind      { return e.typ.Kind() }
+func (e *conversionError) Type() reflect.Type      { return e.typ }
+
+func (e *conversionError) Translate(ut.Translator) string {
+	return Translate(DefaultLanguage(), e)
+}
+
+// BindParams bind the path, query and header parameters of a request into the fields
+// of the struct pointed to by i tagged param, query or header, then validate it as
+// ValidateRequest does. Missing and empty parameters leave the value of their default
+// tag. Parameters of the wrong type are reported with the validation errors, in a
+// ParamErrors, while a default of the wrong type is returned as a bind error.
+//
+//  type ListRequest struct {
+//      Limit  int    `query:"limit" default:"10" validate:"min=1,max=100"`
+//      Sort   string `query:"sort" default:"name" validate:"oneof=name created_at"`
+//      Tenant string `header:"X-Tenant-ID"`
+//  }
+func BindParams(ctx echo.Context, i interface{}) error {
+	value := reflect.ValueOf(i)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind params: %T is not a pointer to a struct", i)
+	}
+	value = value.Elem()
+
+	var conversionErrors ParamErrors
+	if err := bindParams(ctx, value, value.Type().Name(), value.Type().Name(), &conversionErrors); err != nil {
+		return err
+	}
+
+	err := ValidateRequest(ctx, i)
+	if len(conversionErrors) == 0 {
+		return err
+	}
+	var validationErrors validator.ValidationErrors
+	if err != nil && !errors.As(err, &validationErrors) {
+		return err
+	}
+	converted := map[string]bool{}
+	for _, conversionErr := range conversionErrors {
+		converted[conversionErr.StructNamespace()] = true
+	}
+	for _, validationErr := range validationErrors {
+		if !converted[validationErr.StructNamespace()] {
+			conversionErrors = append(conversionErrors, validationErr)
+		}
+	}
+	return conversionErrors
+}
+
+// bindParams bind the parameters into the fields of a struct, and of its embedded structs.
+func bindParams(ctx echo.Context, value reflect.Value, namespace, structNamespace string, conversionErrors *ParamErrors) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			// the validator names the fields of embedded structs after the struct
+			if err := bindParams(ctx, value.Field(i), namespace+"."+field.Name, structNamespace+"."+field.Name, conversionErrors); err != nil {
+				return err
+			}
+			continue
+		}
+		values, ok := paramValues(ctx, field)
+		if !ok {
+			continue
+		}
+		defaulted := false
+		if len(values) == 0 {
+			if defaultValue, ok := field.Tag.Lookup("default"); ok {
+				values, defaulted = []string{defaultValue}, true
+			}
+		}
+		if len(values) == 0 || !value.Field(i).CanSet() {
+			continue
+		}
+
+		tag, err := setParam(value.Field(i), values)
+		if err != nil {
+			return fmt.Errorf("bind params: field %s: %w", field.Name, err)
+		}
+		if tag != "" && defaulted {
+			// a mistake of the struct, not of the client
+			return fmt.Errorf("bind params: field %s: default %q is not a %s", field.Name, values[0], tag)
+		}
+		if tag != "" {
+			name := fieldName(field)
+			if name == "" {
+				name = field.Name
+			}
+			*conversionErrors = append(*conversionErrors, &conversionError{
+				tag:             tag,
+				namespace:       namespace + "." + name,
+				structNamespace: structNamespace + "." + field.Name,
+				field:           name,
+				structField:     field.Name,
+				value:           strings.Join(values, ","),
+				typ:             field.Type,
+			})
+		}
+	}
+	return nil
+}
+
+// paramValues return the non-empty values of the parameter of a field, false when the
+// field is not bound to a parameter.
+func paramValues(ctx echo.Context, field reflect.StructField) ([]string, bool) {
+	for _, source := range paramSources {
+		name, ok := field.Tag.Lookup(source)
+		if !ok || name == "" || name == "-" {
+			continue
+		}
+		var values []string
+		switch source {
+		case "param":
+			values = []string{ctx.Param(name)}
+		case "query":
+			values = ctx.QueryParams()[name]
+		case "header":
+			values = ctx.Request().Header.Values(name)
+		}
+		nonEmpty := make([]string, 0, len(values))
+		for _, value := range values {
+			if value != "" {
+				nonEmpty = append(nonEmpty, value)
+			}
+		}
+		return nonEmpty, true
+	}
+	return nil, false
+}
+
+// setParam set a field from the values of its parameter, returning the tag of the
+// error of values not converted to its type.
+func setParam(field reflect.Value, values []string) (string, error) {
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if tag, err := setValue(slice.Index(i), value); tag != "" || err != nil {
+				return tag, err
+			}
+		}
+		field.Set(slice)
+		return "", nil
+	}
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) (string, error) {
+	if field.Kind() == reflect.Ptr {
+		pointer := reflect.New(field.Type().Elem())
+		if tag, err := setValue(pointer.Elem(), value); tag != "" || err != nil {
+			return tag, err
+		}
+		field.Set(pointer)
+		return "", nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return "number", nil
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return "number", nil
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return "number", nil
+		}
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "boolean", nil
+		}
+		field.SetBool(parsed)
+	default:
+		return "", fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return "", nil
+}
@@ -0,0 +1,97 @@
+package validation_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pagination struct {
+	Page     int `query:"page" default:"1" validate:"min=1"`
+	PageSize int `query:"pageSize" default:"10" validate:"min=1,max=100"`
+}
+
+type searchParams struct {
+	pagination
+	Category string   `param:"category" validate:"required,slug"`
+	Tags     []string `query:"tag" validate:"max=3"`
+	Active   *bool    `query:"active"`
+	MinPrice float64  `query:"minPrice"`
+	Tenant   string   `header:"X-Tenant-ID" default:"public"`
+	Internal string
+}
+
+func bindSearch(t *testing.T, target, category string, header http.Header) (searchParams, error) {
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	ctx := e.NewContext(request, httptest.NewRecorder())
+	ctx.SetParamNames("category")
+	ctx.SetParamValues(category)
+
+	var params searchParams
+	err := validation.BindParams(ctx, &params)
+	return params, err
+}
+
+func TestBindParams(t *testing.T) {
+	params, err := bindSearch(t, "/products?tag=a&tag=b&active=true&minPrice=9.5&Internal=x", "books", http.Header{"X-Tenant-Id": {"acme"}})
+	require.NoError(t, err)
+	active := true
+	assert.Equal(t, searchParams{
+		pagination: pagination{Page: 1, PageSize: 10},
+		Category:   "books",
+		Tags:       []string{"a", "b"},
+		Active:     &active,
+		MinPrice:   9.5,
+		Tenant:     "acme",
+	}, params, "defaults apply to missing parameters, untagged fields are not bound")
+
+	params, err = bindSearch(t, "/products?page=&pageSize=20", "books", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, params.Page, "empty parameters take their default")
+	assert.Equal(t, 20, params.PageSize)
+	assert.Equal(t, "public", params.Tenant)
+}
+
+func TestBindParamsErrors(t *testing.T) {
+	_, err := bindSearch(t, "/products?page=two&pageSize=500&active=maybe", "Not A Slug", nil)
+	var paramErrors validation.ParamErrors
+	require.True(t, errors.As(err, &paramErrors), "%v", err)
+
+	tags := map[string]string{}
+	for _, fieldErr := range paramErrors {
+		tags[validation.JSONPointer(fieldErr.Namespace())] = fieldErr.Tag()
+	}
+	assert.Equal(t, map[string]string{
+		"/pagination/page":     "number",
+		"/pagination/pageSize": "max",
+		"/active":              "boolean",
+		"/category":            "slug",
+	}, tags, "a parameter of the wrong type is not validated further")
+
+	_, err = bindSearch(t, "/products?tag=a&tag=b&tag=c&tag=d", "books", nil)
+	assert.Equal(t, map[string]string{"tag": "max"}, failedTags(t, err))
+
+	e := echo.New()
+	ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	assert.Error(t, validation.BindParams(ctx, searchParams{}), "a pointer is required")
+
+	var invalidDefault struct {
+		Limit int `query:"limit" default:"ten"`
+	}
+	err = validation.BindParams(ctx, &invalidDefault)
+	assert.EqualError(t, err, `bind params: field Limit: default "ten" is not a number`)
+	assert.False(t, errors.As(err, &paramErrors), "an invalid default is not an error of the request")
+	require.NoError(t, validation.ErrorResponse(ctx, err))
+	assert.Equal(t, http.StatusInternalServerError, ctx.Response().Status)
+}
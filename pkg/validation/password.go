@@ -0,0 +1,376 @@
+package validation
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// passwordTags the rules of the password tag, each passing when disabled by the policy.
+const passwordTags = "password_length,password_lower,password_upper,password_digit,password_symbol,password_strength,password_breached"
+
+// minUsernameLength length under which usernames are not looked for in passwords.
+const minUsernameLength = 3
+
+// PasswordPolicy requirements of the password tag.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// DisallowUsername reject the passwords containing the field named by the
+	// password_username tag.
+	DisallowUsername bool
+	// MinScore minimum PasswordScore, from 0 to 4.
+	MinScore int
+	// CheckBreached reject the passwords of the bundled breached-password list, and of
+	// BreachedList when set.
+	CheckBreached bool
+	BreachedList  string
+}
+
+var (
+	passwordPolicyMu sync.RWMutex
+	passwordPolicy   PasswordPolicy
+
+	//go:embed breached_passwords.txt
+	bundledBreachedList string
+	bundledBreachedOnce sync.Once
+	bundledBreached     breachedIndex
+	bundledBreachedErr  error
+	breachedListsMu     sync.Mutex
+	breachedLists       = map[string]breachedIndex{}
+)
+
+// PasswordPolicyFromConfig return the password policy of the "password" settings.
+func PasswordPolicyFromConfig() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:        viper.GetInt("password.minLength"),
+		RequireLower:     viper.GetBool("password.requireLower"),
+		RequireUpper:     viper.GetBool("password.requireUpper"),
+		RequireDigit:     viper.GetBool("password.requireDigit"),
+		RequireSymbol:    viper.GetBool("password.requireSymbol"),
+		DisallowUsername: viper.GetBool("password.disallowUsername"),
+		MinScore:         viper.GetInt("password.minScore"),
+		CheckBreached:    viper.GetBool("password.checkBreached"),
+		BreachedList:     viper.GetString("password.breachedList"),
+	}
+}
+
+// SetPasswordPolicy set the policy checked by the password tags, which check nothing
+// until it is set.
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicyMu.Lock()
+	defer passwordPolicyMu.Unlock()
+	passwordPolicy = policy
+}
+
+// GetPasswordPolicy return the policy checked by the password tags.
+func GetPasswordPolicy() PasswordPolicy {
+	passwordPolicyMu.RLock()
+	defer passwordPolicyMu.RUnlock()
+	return passwordPolicy
+}
+
+func validatePasswordLength(fl validator.FieldLevel) bool {
+	return utf8.RuneCountInString(fl.Field().String()) >= GetPasswordPolicy().MinLength
+}
+
+func validatePasswordLower(fl validator.FieldLevel) bool {
+	return !GetPasswordPolicy().RequireLower || strings.IndexFunc(fl.Field().String(), unicode.IsLower) >= 0
+}
+
+func validatePasswordUpper(fl validator.FieldLevel) bool {
+	return !GetPasswordPolicy().RequireUpper || strings.IndexFunc(fl.Field().String(), unicode.IsUpper) >= 0
+}
+
+func validatePasswordDigit(fl validator.FieldLevel) bool {
+	return !GetPasswordPolicy().RequireDigit || strings.IndexFunc(fl.Field().String(), unicode.IsDigit) >= 0
+}
+
+func validatePasswordSymbol(fl validator.FieldLevel) bool {
+	return !GetPasswordPolicy().RequireSymbol || strings.IndexFunc(fl.Field().String(), isSymbol) >= 0
+}
+
+func validatePasswordStrength(fl validator.FieldLevel) bool {
+	minScore := GetPasswordPolicy().MinScore
+	return minScore <= 0 || PasswordScore(fl.Field().String()) >= minScore
+}
+
+// validatePasswordUsername check a password does not contain, case-insensitively,
+// the username of the field given as parameter: password_username=Username.
+func validatePasswordUsername(fl validator.FieldLevel) bool {
+	if !GetPasswordPolicy().DisallowUsername {
+		return true
+	}
+	username, kind, ok := fl.GetStructFieldOK()
+	if !ok || kind != reflect.String || utf8.RuneCountInString(username.String()) < minUsernameLength {
+		return true
+	}
+	return !strings.Contains(strings.ToLower(fl.Field().String()), strings.ToLower(username.String()))
+}
+
+func validatePasswordBreached(ctx context.Context, fl validator.FieldLevel) bool {
+	policy := GetPasswordPolicy()
+	if !policy.CheckBreached || fl.Field().String() == "" {
+		return true
+	}
+	breached, err := PasswordBreached(policy, fl.Field().String())
+	if err != nil {
+		return ruleFailed(ctx, fl, err)
+	}
+	return !breached
+}
+
+// PasswordBreached tell whether a password is on the bundled breached-password list
+// or on the BreachedList of the policy.
+func PasswordBreached(policy PasswordPolicy, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	bundledBreachedOnce.Do(func() {
+		bundledBreached, bundledBreachedErr = readBreachedList(strings.NewReader(bundledBreachedList))
+	})
+	if bundledBreachedErr != nil {
+		return false, bundledBreachedErr
+	}
+	index := bundledBreached
+	if index.contains(hash) {
+		return true, nil
+	}
+	if policy.BreachedList == "" {
+		return false, nil
+	}
+	index, err := loadBreachedList(policy.BreachedList)
+	if err != nil {
+		return false, err
+	}
+	return index.contains(hash), nil
+}
+
+// breachedIndex the suffixes of the SHA-1 hashes of breached passwords by their
+// first five characters, the ranges of the Pwned Passwords API.
+type breachedIndex map[string]map[string]struct{}
+
+func (index breachedIndex) contains(hash string) bool {
+	_, ok := index[hash[:5]][hash[5:]]
+	return ok
+}
+
+// loadBreachedList load a breached-password list file once.
+func loadBreachedList(path string) (breachedIndex, error) {
+	breachedListsMu.Lock()
+	defer breachedListsMu.Unlock()
+	if index, ok := breachedLists[path]; ok {
+		return index, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password list: %w", err)
+	}
+	defer file.Close()
+	index, err := readBreachedList(file)
+	if err != nil {
+		return nil, fmt.Errorf("read breached password list %s: %w", path, err)
+	}
+	breachedLists[path] = index
+	return index, nil
+}
+
+// readBreachedList read a list of upper case SHA-1 hashes, one "HASH[:COUNT]" per line
+// as in the Pwned Passwords downloads, ignoring blank lines and "#" comments.
+func readBreachedList(reader io.Reader) (breachedIndex, error) {
+	index := breachedIndex{}
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		hash := strings.TrimSpace(scanner.Text())
+		if hash == "" || strings.HasPrefix(hash, "#") {
+			continue
+		}
+		if i := strings.IndexByte(hash, ':'); i >= 0 {
+			hash = hash[:i]
+		}
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("line %d: not a SHA-1 hash", line)
+		}
+		if index[hash[:5]] == nil {
+			index[hash[:5]] = map[string]struct{}{}
+		}
+		index[hash[:5]][hash[5:]] = struct{}{}
+	}
+	return index, scanner.Err()
+}
+
+// commonWords words guessed early by password crackers, after the breached passwords.
+var commonWords = strings.Fields(`
+	password passwd pass secret admin administrator root login welcome letmein master
+	qwerty azerty dragon monkey shadow sunshine princess football baseball soccer hockey
+	superman batman iloveyou love hello freedom whatever trustno nothing access ninja
+	summer winter spring autumn monday friday january december hunter killer cheese
+	michael jennifer jordan thomas charlie robert daniel jessica ashley michelle andrew
+	computer internet google facebook starwars pokemon matrix orange banana chocolate
+	purple yellow silver golden diamond flower tiger lion eagle angel heaven
+	jakarta indonesia bandung surabaya rahasia sayang cinta bismillah merdeka garuda
+`)
+
+// keyboardRows rows of a QWERTY keyboard, for the runs of adjacent keys.
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// leetSubstitutions characters commonly substituted for letters.
+var leetSubstitutions = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "@", "a", "$", "s", "5", "s", "7", "t", "!", "i")
+
+// yearRange number of the years, from 1900 to 2099, guessed in passwords.
+const yearRange = 200
+
+// scoreThresholds bits of entropy of the password scores 1 to 4, those of 10^3, 10^6,
+// 10^8 and 10^10 guesses.
+var scoreThresholds = []float64{9.97, 19.93, 26.58, 33.22}
+
+// PasswordScore estimate the strength of a password from 0 (too guessable) to 4 (very
+// unguessable), as zxcvbn does: the password is split into the common words, repeats,
+// sequences, keyboard runs and years guessed first by crackers, the rest being brute-forced,
+// and scored by the bits of entropy of the cheapest split.
+func PasswordScore(password string) int {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+	bruteForce := math.Log2(float64(charsetSize(password)))
+
+	// bits[i] the bits of entropy of the cheapest split of the first i characters.
+	bits := make([]float64, len(runes)+1)
+	for i := 1; i <= len(runes); i++ {
+		bits[i] = math.Inf(1)
+	}
+	for i := 0; i < len(runes); i++ {
+		bits[i+1] = math.Min(bits[i+1], bits[i]+bruteForce)
+		for _, match := range patternMatches(runes, lower, i) {
+			bits[i+match.length] = math.Min(bits[i+match.length], bits[i]+match.bits)
+		}
+	}
+
+	score := 0
+	for _, threshold := range scoreThresholds {
+		if bits[len(runes)] >= threshold {
+			score++
+		}
+	}
+	return score
+}
+
+type patternMatch struct {
+	length int
+	bits   float64
+}
+
+// patternMatches return the patterns starting at the character i.
+func patternMatches(runes, lower []rune, i int) []patternMatch {
+	var matches []patternMatch
+	rest := string(lower[i:])
+	unleet := leetSubstitutions.Replace(rest)
+	wordBits := math.Log2(float64(len(commonWords)))
+	for _, word := range commonWords {
+		length := utf8.RuneCountInString(word)
+		switch {
+		case strings.HasPrefix(rest, word):
+			matches = append(matches, patternMatch{length, wordBits + capitalizationBits(runes[i:i+length])})
+		case len(unleet) == len(rest) && strings.HasPrefix(unleet, word):
+			matches = append(matches, patternMatch{length, wordBits + 1 + capitalizationBits(runes[i:i+length])})
+		}
+	}
+
+	if length := runLength(lower, i, func(previous, current rune) bool { return current == previous }); length >= 3 {
+		matches = append(matches, patternMatch{length, math.Log2(float64(charsetSize(string(runes[i]))) * float64(length))})
+	}
+	for _, delta := range []rune{1, -1} {
+		delta := delta
+		if length := runLength(lower, i, func(previous, current rune) bool { return current-previous == delta }); length >= 3 {
+			matches = append(matches, patternMatch{length, math.Log2(float64(charsetSize(string(runes[i]))) * float64(length) * 2)})
+		}
+	}
+	if length := runLength(lower, i, keyboardAdjacent); length >= 3 {
+		matches = append(matches, patternMatch{length, math.Log2(float64(len(keyboardRows)*len(keyboardRows[0])) * float64(length))})
+	}
+	if len(rest) >= 4 && (strings.HasPrefix(rest, "19") || strings.HasPrefix(rest, "20")) &&
+		strings.IndexFunc(rest[2:4], func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+		matches = append(matches, patternMatch{4, math.Log2(yearRange)})
+	}
+	return matches
+}
+
+// runLength return the length of the run of characters starting at i, each following
+// the previous one.
+func runLength(runes []rune, i int, follows func(previous, current rune) bool) int {
+	length := 1
+	for i+length < len(runes) && follows(runes[i+length-1], runes[i+length]) {
+		length++
+	}
+	return length
+}
+
+// keyboardAdjacent tell whether current is next to previous on a keyboard row.
+func keyboardAdjacent(previous, current rune) bool {
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, previous)
+		if i < 0 {
+			continue
+		}
+		return (i+1 < len(row) && rune(row[i+1]) == current) || (i > 0 && rune(row[i-1]) == current)
+	}
+	return false
+}
+
+// capitalizationBits return the bits of entropy added by the capitalization of a word.
+func capitalizationBits(word []rune) float64 {
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case upper == len(word) || (upper == 1 && unicode.IsUpper(word[0])):
+		return 1
+	}
+	return float64(len(word))
+}
+
+// charsetSize return the size of the character classes of a password.
+func charsetSize(password string) int {
+	size := 0
+	for _, class := range []struct {
+		is   func(rune) bool
+		size int
+	}{{unicode.IsLower, 26}, {unicode.IsUpper, 26}, {unicode.IsDigit, 10}, {isSymbol, 33}} {
+		if strings.IndexFunc(password, class.is) >= 0 {
+			size += class.size
+		}
+	}
+	if size == 0 {
+		return 1
+	}
+	return size
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
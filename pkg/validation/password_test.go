@@ -0,0 +1,136 @@
+package validation_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type signUp struct {
+	Username string `validate:"required"`
+	Password string `validate:"required,password,password_username=Username"`
+}
+
+func setPasswordPolicy(t *testing.T, policy validation.PasswordPolicy) {
+	previous := validation.GetPasswordPolicy()
+	validation.SetPasswordPolicy(policy)
+	t.Cleanup(func() { validation.SetPasswordPolicy(previous) })
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	setPasswordPolicy(t, validation.PasswordPolicy{
+		MinLength:        10,
+		RequireLower:     true,
+		RequireUpper:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowUsername: true,
+		MinScore:         3,
+		CheckBreached:    true,
+	})
+	v := validation.NewValidator()
+
+	tests := []struct {
+		name     string
+		password string
+		tag      string
+		message  string
+	}{
+		{"length", "aB3$", "password_length", "The password must be at least 10 characters."},
+		{"lowercase", "ABCDEFGHIJ3$", "password_lower", "The password must contain a lowercase letter."},
+		{"uppercase", "abcdefghij3$", "password_upper", "The password must contain an uppercase letter."},
+		{"digit", "abcdefghiJ$", "password_digit", "The password must contain a digit."},
+		{"symbol", "abcdefghiJ3", "password_symbol", "The password must contain a symbol."},
+		{"strength", "Password123!", "password_strength", "The password is too easy to guess, use a longer password or more words."},
+		{"username", "x7#Kq!alice9Pz", "password_username", "The password may not contain the username."},
+		{"valid", "x7#Kq!mw9Pz@2", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(signUp{Username: "Alice", Password: tt.password})
+			if tt.tag == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var errs validator.ValidationErrors
+			require.True(t, errors.As(err, &errs), "%v", err)
+			require.Len(t, errs, 1)
+			assert.Equal(t, tt.tag, errs[0].ActualTag())
+
+			wrapped := validation.WrapValidationErrors(context.Background(), errs)
+			assert.Equal(t, tt.message, wrapped[0].Message)
+			assert.Empty(t, wrapped[0].Value, "passwords are not echoed")
+		})
+	}
+
+	err := v.Validate(signUp{Username: "Alice", Password: "abc"})
+	require.Error(t, err)
+	ctx := validation.WithLanguage(context.Background(), "id-ID")
+	wrapped := validation.WrapValidationErrors(ctx, err.(validator.ValidationErrors))
+	assert.Equal(t, "password minimal 10 karakter.", wrapped[0].Message)
+}
+
+func TestPasswordDisabledPolicy(t *testing.T) {
+	setPasswordPolicy(t, validation.PasswordPolicy{})
+	assert.NoError(t, validation.NewValidator().Validate(signUp{Username: "alice", Password: "alice"}))
+}
+
+func TestPasswordBreached(t *testing.T) {
+	breached, err := validation.PasswordBreached(validation.PasswordPolicy{}, "password123")
+	require.NoError(t, err)
+	assert.True(t, breached, "bundled list")
+
+	sum := sha1.Sum([]byte("Tr0ub4dor&3"))
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(list, []byte("# leaked\n"+strings.ToUpper(hex.EncodeToString(sum[:]))+":42\n"), 0o600))
+	policy := validation.PasswordPolicy{CheckBreached: true, BreachedList: list}
+	breached, err = validation.PasswordBreached(policy, "Tr0ub4dor&3")
+	require.NoError(t, err)
+	assert.True(t, breached, "configured list")
+	breached, err = validation.PasswordBreached(policy, "x7#Kq!mw9Pz@2")
+	require.NoError(t, err)
+	assert.False(t, breached)
+
+	setPasswordPolicy(t, policy)
+	err = validation.NewValidator().Validate(signUp{Username: "alice", Password: "Tr0ub4dor&3"})
+	var errs validator.ValidationErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	assert.Equal(t, "password_breached", errs[0].ActualTag())
+
+	setPasswordPolicy(t, validation.PasswordPolicy{CheckBreached: true, BreachedList: filepath.Join(t.TempDir(), "missing.txt")})
+	err = validation.NewValidator().Validate(signUp{Username: "alice", Password: "x7#Kq!mw9Pz@2"})
+	var ruleErr *validation.RuleError
+	assert.True(t, errors.As(err, &ruleErr), "an unreadable list fails the validation: %v", err)
+}
+
+func TestPasswordScore(t *testing.T) {
+	tests := []struct {
+		password string
+		score    int
+	}{
+		{"", 0},
+		{"password", 0},
+		{"aaaaaaaaaaaa", 0},
+		{"qwertyuiop", 0},
+		{"abcdef123456", 1},
+		{"P@ssw0rd", 0},
+		{"hunter22", 1},
+		{"sunshine2020", 1},
+		{"sunshine7392", 3},
+		{"x7#Kq!mw9Pz@2", 4},
+		{"correct horse battery staple", 4},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.score, validation.PasswordScore(tt.password), tt.password)
+	}
+}
@@ -1,49 +1,172 @@
 package validation
 
 import (
-	"github.com/labstack/gommon/log"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
 	"gopkg.in/go-playground/validator.v9"
 	"gopkg.in/guregu/null.v4"
 )
 
+var (
+	rulesMu sync.Mutex
+	// rules custom validation tags, registered on every new Validator. Their messages
+	// are in the catalogs.
+	rules = map[string]validator.FuncCtx{
+		"date_only":     withoutContext(validateDateOnly),
+		"unique":        validateUnique,
+		"enum":          withoutContext(validateEnum),
+		"digit":         withoutContext(validateOnlyNumber),
+		"unique_update": validateUpdateUnique,
+		"rfe":           withoutContext(validateRequireIfAnotherField),
+		"exists":        validateExists,
+		"exists_except": validateExistsExcept,
+		"exists_all":    validateExistsAll,
+		"datetime":      withoutContext(validateDatetime),
+		"timezone":      withoutContext(validateTimezone),
+		"uuid_or_empty": withoutContext(validateUUIDOrEmpty),
+		"slug":          withoutContext(validateSlug),
+		"phone_number":  withoutContext(validatePhoneNumber),
+		"file":          withoutContext(validateFile),
+		"file_mime":     validateFileMime,
+		"file_size":     withoutContext(validateFileSize),
+
+		"normalized_max": validateNormalizedMax,
+
+		"password_length":   withoutContext(validatePasswordLength),
+		"password_lower":    withoutContext(validatePasswordLower),
+		"password_upper":    withoutContext(validatePasswordUpper),
+		"password_digit":    withoutContext(validatePasswordDigit),
+		"password_symbol":   withoutContext(validatePasswordSymbol),
+		"password_strength": withoutContext(validatePasswordStrength),
+		"password_breached": validatePasswordBreached,
+		"password_username": withoutContext(validatePasswordUsername),
+	}
+	// ruleMessages message templates of the rules registered with RegisterRule.
+	ruleMessages = map[string]string{}
+)
+
 type Validator struct {
 	validator *validator.Validate
 }
 
+// NewValidator create a Validator with the custom rules and types registered.
 func NewValidator() *Validator {
+	v := validator.New()
+
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	for tag, fn := range rules {
+		// the tags and functions are checked by RegisterRule
+		_ = v.RegisterValidationCtx(tag, fn)
+	}
+	v.RegisterTagNameFunc(fieldName)
+	v.RegisterAlias("password", passwordTags)
+	v.RegisterCustomTypeFunc(nullFloatValidator, null.Float{})
+	v.RegisterCustomTypeFunc(nullIntValidator, null.Int{})
+	v.RegisterCustomTypeFunc(nullTimeValidator, null.Time{})
+	v.RegisterCustomTypeFunc(nullStringValidator, null.String{})
+	v.RegisterCustomTypeFunc(fileHeaderValidator, multipart.FileHeader{})
+
 	return &Validator{
-		validator: validator.New(),
+		validator: v,
 	}
 }
 
-func (v *Validator) Validate(i interface{}) error {
+// RegisterRule register a custom validation tag on the validators created from then
+// on, so before the echo validator is set. The message template, with the {field},
+// {param}, {value} and {tag} placeholders, is used for the languages whose catalog
+// has no message for the tag.
+//
+//  validation.RegisterRule("sku", func(fl validator.FieldLevel) bool {
+//      return skuPattern.MatchString(fl.Field().String())
+//  }, "The {field} must be a valid SKU.")
+func RegisterRule(tag string, fn validator.Func, messageTemplate string) error {
+	if fn == nil {
+		return fmt.Errorf("register validation rule %q: function is nil", tag)
+	}
+	return RegisterRuleCtx(tag, withoutContext(fn), messageTemplate)
+}
 
-	if err := v.validator.RegisterValidation("date_only", validateDateOnly); err != nil {
-		log.Error("failed register validation date_only")
-		return err
+// RegisterRuleCtx same as RegisterRule for a rule given the context of the validation,
+// as needed to query the database in the transaction of the request.
+func RegisterRuleCtx(tag string, fn validator.FuncCtx, messageTemplate string) error {
+	if err := validator.New().RegisterValidationCtx(tag, fn); err != nil {
+		return fmt.Errorf("register validation rule %q: %w", tag, err)
 	}
-	if err := v.validator.RegisterValidation("unique", validateUnique); err != nil {
-		log.Error("failed register validation unique")
-		return err
+
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[tag] = fn
+	ruleMessages[tag] = messageTemplate
+	return nil
+}
+
+// fieldName return the name of a field in the requests, from its json, form, query,
+// param or header tag, for the errors to name the fields as the clients do.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "query", "param", "header"} {
+		name := strings.SplitN(field.Tag.Get(key), ",", 2)[0]
+		if name != "" && name != "-" {
+			return name
+		}
 	}
-	if err := v.validator.RegisterValidation("enum", validateEnum); err != nil {
-		log.Error("failed register validation enum")
-		return err
+	return ""
+}
+
+func withoutContext(fn validator.Func) validator.FuncCtx {
+	return func(ctx context.Context, fl validator.FieldLevel) bool {
+		return fn(fl)
 	}
-	if err := v.validator.RegisterValidation("digit", validateOnlyNumber); err != nil {
-		log.Error("failed register validation digit")
-		return err
+}
+
+// Validate validate i, without a request context. It implements echo.Validator.
+func (v *Validator) Validate(i interface{}) error {
+	return v.ValidateCtx(context.Background(), i)
+}
+
+// ValidateCtx validate i in the context of a request, the database rules querying
+// its transaction or tenant. It returns validator.ValidationErrors for invalid
+// values, or a *RuleError when a rule could not check a value.
+func (v *Validator) ValidateCtx(ctx context.Context, i interface{}) error {
+	errs := &ruleErrors{}
+	err := v.validator.StructCtx(context.WithValue(ctx, ruleErrorsContextKey{}, errs), i)
+	if errs.err != nil {
+		return errs.err
 	}
-	if err := v.validator.RegisterValidation("unique_update", validateUpdateUnique); err != nil {
-		log.Error("failed register validation unique_update")
-		return err
+	return err
+}
+
+// ValidateRequest validate i with the validator of the echo instance, in the context
+// of the request.
+func ValidateRequest(ctx echo.Context, i interface{}) error {
+	if v, ok := ctx.Echo().Validator.(*Validator); ok {
+		return v.ValidateCtx(ctx.Request().Context(), i)
 	}
-	if err := v.validator.RegisterValidation("rfe", validateRequireIfAnotherField); err != nil {
-		log.Error("failed register validation rfe")
-		return err
+	return ctx.Validate(i)
+}
+
+// ErrorResponse answer the error of a validation: 422 with the validation errors
+// grouped by the JSON Pointer of their field, or 500 when a rule could not check
+// a value.
+func ErrorResponse(ctx echo.Context, err error) error {
+	var validationErrors validator.ValidationErrors
+	var paramErrors ParamErrors
+	if errors.As(err, &paramErrors) {
+		// WrapValidationErrors only reads the field errors, so they need not come
+		// from the validator
+		validationErrors = validator.ValidationErrors(paramErrors)
+	} else if !errors.As(err, &validationErrors) {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"message": http.StatusText(http.StatusInternalServerError)})
 	}
-	v.validator.RegisterCustomTypeFunc(nullFloatValidator, null.Float{})
-	v.validator.RegisterCustomTypeFunc(nullIntValidator, null.Int{})
-	v.validator.RegisterCustomTypeFunc(nullTimeValidator, null.Time{})
-	return v.validator.Struct(i)
+	return ctx.JSON(http.StatusUnprocessableEntity,
+		echo.Map{"message": http.StatusText(http.StatusUnprocessableEntity),
+			"errors": GroupValidationErrors(WrapValidationErrors(ctx.Request().Context(), validationErrors))})
 }
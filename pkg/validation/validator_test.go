@@ -0,0 +1,53 @@
+package validation_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alpakih/go-api/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type product struct {
+	SKU string `validate:"sku"`
+}
+
+func TestRegisterRule(t *testing.T) {
+	require.NoError(t, validation.RegisterRule("sku", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "SKU-")
+	}, "The {field} {value} must be a valid SKU."))
+	assert.Error(t, validation.RegisterRule("", nil, ""))
+
+	v := validation.NewValidator()
+	assert.NoError(t, v.Validate(product{SKU: "SKU-1"}))
+	err := v.Validate(product{SKU: "1"})
+	require.Error(t, err)
+
+	ctx := validation.WithLanguage(context.Background(), "id-ID")
+	errs := validation.WrapValidationErrors(ctx, err.(validator.ValidationErrors))
+	require.Len(t, errs, 1)
+	assert.Equal(t, "The sku 1 must be a valid SKU.", errs[0].Message, "templates are used for every language without a catalog entry")
+}
+
+type benchmarkRequest struct {
+	Name     string `validate:"required,max=50"`
+	Birthday string `validate:"date_only"`
+	Phone    string `validate:"digit"`
+	Role     string `validate:"enum=admin_member"`
+}
+
+func BenchmarkValidate(b *testing.B) {
+	v := validation.NewValidator()
+	request := benchmarkRequest{Name: "alice", Birthday: "2000-01-31", Phone: "0812", Role: "admin"}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := v.Validate(request); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}